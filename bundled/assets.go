@@ -6,6 +6,7 @@
 // assets/fields.yaml
 // assets/salt.bin
 // assets/vat-template.xlsx
+// assets/vat-templates.yaml
 package bundled
 
 import (
@@ -202,6 +203,26 @@ func assetsVatTemplateXlsx() (*asset, error) {
 	return a, nil
 }
 
+var _assetsVatTemplatesYaml = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x75\x54\x3b\x6e\xdb\x40\x10\xed\x75\x8a\x81\x5c\xa4\x11\x65\x52\x4a\xec\x84\x5d\x90\x20\x45\x10\x03\x29\x0c\xb7\xc4\x90\x1c\x51\x13\xed\x47\xd8\x5d\x4a\x32\x02\xdf\x26\x57\x48\xe7\x4e\x17\xcb\x70\x49\x0a\xb6\xac\x00\x2c\x76\xde\xbc\x79\xf3\xdb\xe5\x15\x3c\x90\xf3\x6c\x8d\x07\xbb\x82\xb0\x26\x78\xf8\x7c\x0f\x8e\x56\xad\xa9\x61\x65\x9d\x86\x95\xb3\x3a\x3a\xbe\x59\x47\xdc\x18\xb8\x63\xc3\x3e\xb8\xc7\x39\xc0\xbd\xc0\xbb\x3e\x1e\x5a\x4f\x35\xb0\x8f\x54\x85\x81\x7c\x00\x6b\x48\x4c\x0c\xe0\x03\xba\x20\x19\x0c\x58\x37\xb9\x82\x92\x44\x99\x22\xd3\xb7\xa5\x66\x1f\x05\x6a\x09\x12\xcd\x2f\xa4\x94\x07\x14\x7f\x9a\x94\xd8\x89\x3a\xbb\x17\x40\xea\xa9\xac\x6a\xb5\x89\x3a\x5d\xec\x8a\x9d\x24\xf1\x6b\xa2\x30\x16\x1f\x48\x6f\x55\xaf\xf3\x03\x4b\xea\x85\x24\x63\xb5\xa6\x6a\x23\x52\xd8\x20\x1b\x09\x7a\xc9\x05\x6f\x01\xa5\xe4\x47\xdb\x06\xa8\xd0\xbc\x0b\x52\x60\xdf\xce\x9e\xc3\x3a\x72\xf7\xce\x9a\x06\xfc\xd6\x11\xd6\x31\xe1\x7c\x92\x8c\x9d\xe7\x30\x5d\xa4\x8b\x74\x3a\x81\x38\xab\x1c\x3a\x2b\x49\x33\xf9\x04\x1a\xb3\xe4\x80\xde\x53\xf0\xd7\x3b\x0c\xc9\xa9\xcc\x83\xf2\x07\x21\x69\x3c\x14\x8a\x0d\xf9\x1c\xb2\x5b\xb1\xab\x6e\x06\xb9\x1c\x00\x0c\x6a\xea\x4f\x00\x09\xfc\x96\x59\xe4\xf0\x71\xd6\x8d\x22\x87\xf4\xe9\xcc\x91\xa5\xaf\x3d\x35\x6f\x95\xd5\x18\xb8\x2a\xb8\xfe\x9f\xca\xb2\xe7\x96\x68\x36\xe7\x94\x6c\xf1\x5a\x4f\x5b\x13\xd6\x6f\x48\x37\x2f\x49\x7d\x1b\x91\x12\xf7\x53\x44\xce\x22\x8b\xc8\xb0\xc0\x51\xc1\xb4\xba\x24\x27\x81\x83\xbd\x23\x53\x5b\xb1\xb3\xc1\x76\x54\x11\x6f\x43\x31\xf2\x16\x03\x5e\xc7\x79\x2e\x07\x2b\xd8\x80\x92\xfd\xfd\x28\x82\x21\x87\x0f\xdd\x32\xe4\xf6\x62\x18\x92\xf5\x95\xc3\x77\x34\x49\x7a\x13\x11\xd4\xb6\x35\x42\x9d\xa6\xf3\x34\x2e\x6f\x84\x8a\x65\x51\x73\xc3\xa3\x2b\xfa\x54\xbc\x4c\xbd\xd4\xd8\xf7\xa7\xb1\xed\x99\x2c\xf9\xd0\xd1\xef\xd1\x06\x45\xbf\x10\x0c\x6b\x9e\x3e\x5d\x64\x2f\x4f\xec\xaf\xfd\x72\xb0\x66\xb9\x4a\xc7\xbf\x2c\x38\x75\x2f\xab\xf5\x1b\x44\x57\x33\x18\x37\x3f\x13\xc9\x96\x17\x72\x36\xd8\xc5\xc8\x35\x46\x94\xd4\x5b\x34\xcd\xc5\x02\xb2\xdb\x37\xb1\x3f\xc9\xb1\xb5\xf5\x0c\x34\x2b\x45\x40\xdd\x9b\xdd\x1c\xff\x70\x49\x1a\x37\xbe\x85\x10\xb5\x51\x1e\xa8\xbc\x98\xd8\x5b\x10\x9c\xce\x85\xc7\xd6\xb2\x93\xf0\xdd\xf1\xf9\xf8\x2c\xc5\x5c\xcb\xdf\xa5\x5b\xa9\x44\xfc\x03\x81\xd9\x54\x70\x69\x04\x00\x00")
+
+func assetsVatTemplatesYamlBytes() ([]byte, error) {
+	return bindataRead(
+		_assetsVatTemplatesYaml,
+		"assets/vat-templates.yaml",
+	)
+}
+
+func assetsVatTemplatesYaml() (*asset, error) {
+	bytes, err := assetsVatTemplatesYamlBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "assets/vat-templates.yaml", size: 1129, mode: os.FileMode(420), modTime: time.Unix(1792415702, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -254,12 +275,13 @@ func AssetNames() []string {
 
 // _bindata is a table, holding each asset generator, mapped to its name.
 var _bindata = map[string]func() (*asset, error){
-	"assets/1.sql":             assets1Sql,
-	"assets/api.bin":           assetsApiBin,
-	"assets/excise.pdf":        assetsExcisePdf,
-	"assets/fields.yaml":       assetsFieldsYaml,
-	"assets/salt.bin":          assetsSaltBin,
-	"assets/vat-template.xlsx": assetsVatTemplateXlsx,
+	"assets/1.sql":              assets1Sql,
+	"assets/api.bin":            assetsApiBin,
+	"assets/excise.pdf":         assetsExcisePdf,
+	"assets/fields.yaml":        assetsFieldsYaml,
+	"assets/salt.bin":           assetsSaltBin,
+	"assets/vat-template.xlsx":  assetsVatTemplateXlsx,
+	"assets/vat-templates.yaml": assetsVatTemplatesYaml,
 }
 
 // AssetDir returns the file names below a certain
@@ -304,12 +326,13 @@ type bintree struct {
 
 var _bintree = &bintree{nil, map[string]*bintree{
 	"assets": &bintree{nil, map[string]*bintree{
-		"1.sql":             &bintree{assets1Sql, map[string]*bintree{}},
-		"api.bin":           &bintree{assetsApiBin, map[string]*bintree{}},
-		"excise.pdf":        &bintree{assetsExcisePdf, map[string]*bintree{}},
-		"fields.yaml":       &bintree{assetsFieldsYaml, map[string]*bintree{}},
-		"salt.bin":          &bintree{assetsSaltBin, map[string]*bintree{}},
-		"vat-template.xlsx": &bintree{assetsVatTemplateXlsx, map[string]*bintree{}},
+		"1.sql":              &bintree{assets1Sql, map[string]*bintree{}},
+		"api.bin":            &bintree{assetsApiBin, map[string]*bintree{}},
+		"excise.pdf":         &bintree{assetsExcisePdf, map[string]*bintree{}},
+		"fields.yaml":        &bintree{assetsFieldsYaml, map[string]*bintree{}},
+		"salt.bin":           &bintree{assetsSaltBin, map[string]*bintree{}},
+		"vat-template.xlsx":  &bintree{assetsVatTemplateXlsx, map[string]*bintree{}},
+		"vat-templates.yaml": &bintree{assetsVatTemplatesYaml, map[string]*bintree{}},
 	}},
 }}
 
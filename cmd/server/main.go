@@ -15,6 +15,7 @@ import (
 
 	"github.com/BTBurke/vatinator"
 	"github.com/BTBurke/vatinator/handlers"
+	"github.com/BTBurke/vatinator/img"
 	magic "github.com/caddyserver/certmagic"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
@@ -44,6 +45,8 @@ func main() {
 	viper.SetDefault("upload_dir", "/var/vat/upload")
 	viper.SetDefault("export_dir", "/var/vat/export")
 	viper.SetDefault("credential_file", "/etc/vat/vatinator-f91ccb107c2c.json")
+	viper.SetDefault("image_format", "jpeg")
+	viper.SetDefault("image_quality", 85)
 	viper.SetEnvPrefix("vat")
 
 	// parse flags then get config
@@ -86,6 +89,17 @@ func main() {
 	log.Printf("Export directory: %s", viper.Get("export_dir"))
 	log.Printf("Using postmark for transactional emails")
 
+	// how receipt images are stored
+	format, err := img.ParseFormat(viper.GetString("image_format"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if format == img.WebP && !img.HasWebPEncoder() {
+		log.Printf("image_format is webp but cwebp is not installed, images will be stored as jpeg")
+	}
+	img.DefaultStorage = img.StorageOptions{Format: format, Quality: viper.GetInt("image_quality")}
+	log.Printf("Storing images as %s quality %d", format, img.DefaultStorage.Quality)
+
 	// set up account service
 	db, err := vatinator.NewDB(filepath.Join(viper.GetString("data_dir"), "vat.db"))
 	if err != nil {
@@ -13,13 +13,16 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/BTBurke/clt"
 	"github.com/BTBurke/vatinator"
 	"github.com/BTBurke/vatinator/bundled"
 	"github.com/BTBurke/vatinator/img"
+	"github.com/BTBurke/vatinator/svc"
 	"github.com/BTBurke/vatinator/update"
 	"github.com/dgraph-io/badger/v2"
+	"github.com/spf13/pflag"
 	"golang.org/x/crypto/nacl/secretbox"
 	"golang.org/x/crypto/scrypt"
 )
@@ -36,8 +39,12 @@ type config struct {
 	FullName     string
 	DiplomaticID string
 	Embassy      string
+	Country      string
 	Address      string
 	Bank         string
+	FileNames    string
+	// Data is a list of receipt data formats to also export, like csv,json
+	Data string `json:",omitempty"`
 }
 
 type task struct {
@@ -50,6 +57,9 @@ func main() {
 		fmt.Printf("Version: %s\nCommit: %s\nDate: %s\n", version, commit, date)
 		os.Exit(0)
 	}
+	data := pflag.String("data", "", "also export the receipt data for bookkeeping as csv, json or csv,json")
+	pflag.Parse()
+
 	hasUpdated, err := checkAndUpdate()
 	if err != nil && errors.Is(err, update.FatalError{}) {
 		fmt.Printf("Update failed: %s. There's a good chance something is very wrong.  You should download the latest version from https://github.com/BTBurke/vatinator just to be sure.", err)
@@ -85,6 +95,18 @@ func main() {
 		}
 	}
 
+	if err := svc.ValidateFileNames(cfg.FileNames); err != nil {
+		log.Fatal(err)
+	}
+
+	if pflag.CommandLine.Changed("data") {
+		cfg.Data = *data
+	}
+	dataFormats, err := svc.ParseDataFormats(cfg.Data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	wd, err := os.Getwd()
 	if err != nil {
 		log.Fatal(err)
@@ -153,19 +175,23 @@ func main() {
 		FullName:     cfg.FullName,
 		DiplomaticID: cfg.DiplomaticID,
 		Embassy:      cfg.Embassy,
+		Country:      cfg.Country,
 		Address:      cfg.Address,
 		Bank:         cfg.Bank,
+		FileNames:    cfg.FileNames,
 	}
 	if !fd.IsValid() {
 		log.Fatal("form data is not valid")
 	}
 
-	if err := vatinator.Process(path, fd, monthString, y, nil); err != nil {
+	opts := vatinator.DefaultOptions(path)
+	opts.DataFormats = dataFormats
+	if err := vatinator.Process(path, fd, monthString, y, opts); err != nil {
 		log.Fatal(err)
 	}
 
 	i.Reset()
-	i.Say("Partial success! See output in %s and review the forms and errors.txt to fix my failings.", filepath.Join(dirs[dirIndex], "out"))
+	i.Say("Partial success! See output in %s and review the summary and forms to fix my failings.", filepath.Join(dirs[dirIndex], "out"))
 	i.Pause()
 }
 
@@ -419,10 +445,13 @@ func setup(cfg *config) error {
 	cfg.DiplomaticID = i.AskWithHint("\nEnter your diplomatic ID", "Starts with B in upper right of dip ID", clt.Required())
 	i.Reset()
 
-	cfg.Embassy = i.AskWithDefault("\nEnter embassy", "US Embassy")
+	cfg.Country = strings.ToUpper(i.AskWithHint("\nEnter the country code of your mission", "3 letters, like USA, GBR or DEU", clt.Required(), countryCode()))
+	i.Reset()
+
+	cfg.Embassy = i.Ask("\nEnter embassy", clt.Required())
 	i.Reset()
 
-	cfg.Address = i.AskWithDefault("\nEnter embassy address", "Kentmanni 20")
+	cfg.Address = i.Ask("\nEnter embassy address", clt.Required())
 	i.Reset()
 
 	i.Say("Now let's set up your banking details.  First choose your bank then input your bank account number.  These numbers never leave your computer.")
@@ -453,7 +482,7 @@ func setup(cfg *config) error {
 	}
 	i.Reset()
 
-	i.Say("Your configuration:\n%s (%s), %s, %s\n%s", cfg.FullName, cfg.DiplomaticID, cfg.Embassy, cfg.Address, cfg.Bank)
+	i.Say("Your configuration:\n%s (%s), %s (%s), %s\n%s", cfg.FullName, cfg.DiplomaticID, cfg.Embassy, cfg.Country, cfg.Address, cfg.Bank)
 	ans := i.AskYesNo("Is this ok", "yes")
 	if clt.IsNo(ans) {
 		return setup(cfg)
@@ -470,6 +499,16 @@ func setup(cfg *config) error {
 	return nil
 }
 
+// countryCode accepts 3 letter country codes like USA
+func countryCode() clt.ValidationFunc {
+	return func(s string) (bool, error) {
+		if len(s) != 3 || strings.IndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+			return false, fmt.Errorf("country code should be 3 letters, like USA")
+		}
+		return true, nil
+	}
+}
+
 func decryptKeyFile() error {
 	dataB64, err := bundled.Asset("assets/api.bin")
 	if err != nil {
@@ -20,6 +20,10 @@ const (
 	Image
 )
 
+// FormatMask is the part of the metadata that holds the format of a Formatted entity.  It only works for entity
+// types above the mask, which is fine for Image.
+const FormatMask byte = 0x07
+
 // EntityTypeError is returned when the value at key does not match the type of the expected entity that it is
 // supposed to be marshaled into
 var EntityTypeError = fmt.Errorf("entity metadata did not match receiver type")
@@ -32,6 +36,15 @@ type Entity interface {
 	encoding.BinaryUnmarshaler
 }
 
+// Formatted is implemented by entities that can be stored in more than one encoding, like images.  The format is
+// saved in the metadata next to the entity type so the value can be decoded without guessing.  Formats must fit
+// in FormatMask.
+type Formatted interface {
+	Entity
+	MarshalFormat() ([]byte, byte, error)
+	UnmarshalFormat(data []byte, format byte) error
+}
+
 // Key is an enterface for keys that know how to marshal and unmarshal themselves from/to []byte
 type Key interface {
 	encoding.BinaryMarshaler
@@ -41,7 +54,16 @@ type Key interface {
 // Set will set a value in the database with an associated entity type and
 // TTL
 func Set(txn *badger.Txn, key Key, e Entity) error {
-	val, err := e.MarshalBinary()
+	var val []byte
+	meta := e.Type()
+	var err error
+	if f, ok := e.(Formatted); ok {
+		var format byte
+		val, format, err = f.MarshalFormat()
+		meta |= format & FormatMask
+	} else {
+		val, err = e.MarshalBinary()
+	}
 	if err != nil {
 		return err
 	}
@@ -50,7 +72,7 @@ func Set(txn *badger.Txn, key Key, e Entity) error {
 		return err
 	}
 
-	entry := badger.NewEntry(k, val).WithMeta(e.Type())
+	entry := badger.NewEntry(k, val).WithMeta(meta)
 	if e.TTL() > 0 {
 		entry.WithTTL(e.TTL())
 	}
@@ -88,6 +110,12 @@ func FromItem(item *badger.Item, e Entity) error {
 	if err != nil {
 		return err
 	}
+	if f, ok := e.(Formatted); ok {
+		if item.UserMeta()&^FormatMask != e.Type() {
+			return EntityTypeError
+		}
+		return f.UnmarshalFormat(b, item.UserMeta()&FormatMask)
+	}
 	if item.UserMeta() != e.Type() {
 		return EntityTypeError
 	}
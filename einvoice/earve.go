@@ -0,0 +1,148 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// eArve is the subset of the Estonian e-invoice standard (EVS 923) needed for the VAT form.  A single file can
+// contain many invoices.
+type eArve struct {
+	XMLName  xml.Name       `xml:"E_Invoice"`
+	Invoices []eArveInvoice `xml:"Invoice"`
+}
+
+type eArveInvoice struct {
+	Seller struct {
+		Name         string `xml:"Name"`
+		RegNumber    string `xml:"RegNumber"`
+		VATRegNumber string `xml:"VATRegNumber"`
+	} `xml:"InvoiceParties>SellerParty"`
+	Info struct {
+		Type struct {
+			Type string `xml:"type,attr"`
+		} `xml:"Type"`
+		InvoiceNumber string `xml:"InvoiceNumber"`
+		InvoiceDate   string `xml:"InvoiceDate"`
+	} `xml:"InvoiceInformation"`
+	Sums struct {
+		VAT []struct {
+			SumBeforeVAT string `xml:"SumBeforeVAT"`
+			VATRate      string `xml:"VATRate"`
+			VATSum       string `xml:"VATSum"`
+		} `xml:"VAT"`
+		TotalVATSum string `xml:"TotalVATSum"`
+		TotalSum    string `xml:"TotalSum"`
+		Currency    string `xml:"Currency"`
+	} `xml:"InvoiceSumGroup"`
+	Items []struct {
+		Description string `xml:"Description"`
+		ItemSum     string `xml:"ItemSum"`
+		ItemTotal   string `xml:"ItemTotal"`
+	} `xml:"InvoiceItem>InvoiceItemGroup>ItemEntry"`
+	// some vendors set the invoice number only as an attribute
+	InvoiceID string `xml:"invoiceId,attr"`
+}
+
+func parseEArve(data []byte) ([]Invoice, error) {
+	var doc eArve
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse e-arve: %v", err)
+	}
+	if len(doc.Invoices) == 0 {
+		return nil, fmt.Errorf("no invoices found in e-arve")
+	}
+
+	var out []Invoice
+	for _, inv := range doc.Invoices {
+		vat := strings.TrimSpace(inv.Sums.TotalVATSum)
+		vatParts := []string{vat}
+		if len(vat) == 0 {
+			vatParts = nil
+			for _, v := range inv.Sums.VAT {
+				vatParts = append(vatParts, v.VATSum)
+			}
+		}
+
+		amounts := append([]string{inv.Sums.TotalSum}, vatParts...)
+		for _, v := range inv.Sums.VAT {
+			amounts = append(amounts, v.SumBeforeVAT, v.VATSum)
+		}
+		precision := precisionOf(amounts...)
+		total, err := parseAmount(inv.Sums.TotalSum, precision)
+		if err != nil {
+			return nil, err
+		}
+		vatSum := 0
+		for _, v := range vatParts {
+			amt, err := parseAmount(v, precision)
+			if err != nil {
+				return nil, err
+			}
+			vatSum += amt
+		}
+
+		var subtotals []TaxSubtotal
+		for _, v := range inv.Sums.VAT {
+			taxable, err := parseAmount(v.SumBeforeVAT, precision)
+			if err != nil {
+				return nil, err
+			}
+			tax, err := parseAmount(v.VATSum, precision)
+			if err != nil {
+				return nil, err
+			}
+			subtotals = append(subtotals, TaxSubtotal{Rate: strings.TrimSpace(v.VATRate), Taxable: taxable, VAT: tax})
+		}
+
+		// credit invoices reduce the amount claimed
+		if inv.Info.Type.Type == "CRE" {
+			total, vatSum = -abs(total), -abs(vatSum)
+			for i := range subtotals {
+				subtotals[i].Taxable, subtotals[i].VAT = -abs(subtotals[i].Taxable), -abs(subtotals[i].VAT)
+			}
+		}
+
+		number := strings.TrimSpace(inv.Info.InvoiceNumber)
+		if len(number) == 0 {
+			number = strings.TrimSpace(inv.InvoiceID)
+		}
+		currency := strings.TrimSpace(inv.Sums.Currency)
+		if len(currency) == 0 {
+			currency = "EUR"
+		}
+
+		invoice := Invoice{
+			Vendor:       strings.TrimSpace(inv.Seller.Name),
+			RegCode:      strings.TrimSpace(inv.Seller.RegNumber),
+			VATNumber:    strings.TrimSpace(inv.Seller.VATRegNumber),
+			Number:       number,
+			Date:         formatDate(inv.Info.InvoiceDate),
+			Total:        total,
+			VAT:          vatSum,
+			Precision:    precision,
+			Currency:     currency,
+			TaxSubtotals: subtotals,
+		}
+		for _, item := range inv.Items {
+			amount := item.ItemTotal
+			if len(strings.TrimSpace(amount)) == 0 {
+				amount = item.ItemSum
+			}
+			invoice.Items = append(invoice.Items, Item{
+				Description: strings.TrimSpace(item.Description),
+				Amount:      strings.TrimSpace(amount),
+			})
+		}
+		out = append(out, invoice)
+	}
+	return out, nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,63 @@
+package einvoice
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEArve(t *testing.T) {
+	f, err := os.Open("testdata/earve.xml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	invoices, err := Parse(f)
+	require.NoError(t, err)
+	require.Len(t, invoices, 2)
+
+	telia := invoices[0]
+	assert.Equal(t, "Telia Eesti AS", telia.Vendor)
+	assert.Equal(t, "10234957", telia.RegCode)
+	assert.Equal(t, "EE100042343", telia.VATNumber)
+	assert.Equal(t, "20230220601120", telia.Number)
+	assert.Equal(t, "20/02/2023", telia.Date)
+	assert.Equal(t, 4999, telia.Total)
+	assert.Equal(t, 833, telia.VAT)
+	assert.Equal(t, 2, telia.Precision)
+	assert.Equal(t, []Item{{"Kodu internet", "35.00"}, {"Mobiilside", "14.99"}}, telia.Items)
+	assert.Contains(t, telia.Lines(), "Kokku 49.99 EUR")
+
+	credit := invoices[1]
+	assert.Equal(t, "K-1001", credit.Number)
+	assert.Equal(t, -14000, credit.Total)
+	assert.Equal(t, -2333, credit.VAT)
+	assert.Equal(t, 3, credit.Precision)
+	assert.Contains(t, credit.Lines(), "KM -2.333 EUR")
+}
+
+func TestParseUnknown(t *testing.T) {
+	_, err := Parse(strings.NewReader(`<Something/>`))
+	assert.Error(t, err)
+}
+
+func TestParseAmount(t *testing.T) {
+	tt := []struct {
+		in     string
+		digits int
+		out    int
+	}{
+		{"120.5", 2, 12050},
+		{"23,333", 3, 23333},
+		{"-8.33", 2, -833},
+		{"7", 2, 700},
+		{"1.2345", 3, 1234},
+	}
+	for _, tc := range tt {
+		out, err := parseAmount(tc.in, tc.digits)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.out, out, tc.in)
+	}
+}
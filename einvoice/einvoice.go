@@ -0,0 +1,195 @@
+// Package einvoice parses machine-readable invoices so they can be added to a batch without OCR
+package einvoice
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Invoice is the data from a structured invoice that is needed for the VAT form.  Amounts are in cents when
+// Precision is 2 or thousandths when Precision is 3.
+type Invoice struct {
+	Vendor    string
+	RegCode   string
+	VATNumber string
+	Number    string
+	// date format dd/mm/yyyy to match receipts processed with OCR
+	Date      string
+	Total     int
+	VAT       int
+	Precision int
+	Currency  string
+	Items     []Item
+	// TaxSubtotals break the VAT down by rate.  Amounts use the same precision as the invoice.
+	TaxSubtotals []TaxSubtotal
+}
+
+// Item is a single line item on the invoice
+type Item struct {
+	Description string
+	Amount      string
+}
+
+// TaxSubtotal is the taxable amount and VAT for a single VAT rate
+type TaxSubtotal struct {
+	// Rate as a percentage, like 20 or 9
+	Rate    string
+	Taxable int
+	VAT     int
+}
+
+// Parse reads all invoices in the document, choosing the format by the root element
+func Parse(r io.Reader) ([]Invoice, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+	switch root {
+	case "E_Invoice":
+		return parseEArve(data)
+	case "Invoice", "CreditNote":
+		return parseUBL(data)
+	default:
+		return nil, fmt.Errorf("unknown e-invoice format: %s", root)
+	}
+}
+
+// rootElement returns the local name of the first element in the document
+func rootElement(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to find root element: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// Lines returns a plain text rendering of the invoice that is used to create the page image for the invoice packet
+func (inv Invoice) Lines() []string {
+	lines := []string{inv.Vendor}
+	switch {
+	case len(inv.RegCode) > 0 && len(inv.VATNumber) > 0:
+		lines = append(lines, fmt.Sprintf("Reg. nr %s  KMKR %s", inv.RegCode, inv.VATNumber))
+	case len(inv.RegCode) > 0:
+		lines = append(lines, fmt.Sprintf("Reg. nr %s", inv.RegCode))
+	case len(inv.VATNumber) > 0:
+		lines = append(lines, fmt.Sprintf("KMKR %s", inv.VATNumber))
+	}
+	lines = append(lines, "", fmt.Sprintf("Arve nr %s", inv.Number), fmt.Sprintf("Kuupäev %s", inv.Date), "")
+
+	width := 0
+	for _, item := range inv.Items {
+		if len([]rune(item.Description)) > width {
+			width = len([]rune(item.Description))
+		}
+	}
+	for _, item := range inv.Items {
+		lines = append(lines, fmt.Sprintf("%-*s  %s", width, item.Description, item.Amount))
+	}
+	if len(inv.Items) > 0 {
+		lines = append(lines, "")
+	}
+	for _, sub := range inv.TaxSubtotals {
+		lines = append(lines, fmt.Sprintf("KM %s%% %s -> %s", sub.Rate, formatAmount(sub.Taxable, inv.Precision), formatAmount(sub.VAT, inv.Precision)))
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("KM %s %s", formatAmount(inv.VAT, inv.Precision), inv.Currency),
+		fmt.Sprintf("Kokku %s %s", formatAmount(inv.Total, inv.Precision), inv.Currency),
+	)
+	return lines
+}
+
+// parseAmount converts a decimal string like 120.5 or -23,333 into an integer with the given number of digits
+// after the decimal point.  Extra digits are truncated.
+func parseAmount(s string, digits int) (int, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	if len(s) == 0 {
+		return 0, nil
+	}
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	parts := strings.SplitN(s, ".", 2)
+	whole, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %s: %v", s, err)
+	}
+	frac := ""
+	if len(parts) == 2 {
+		frac = parts[1]
+	}
+	if len(frac) > digits {
+		frac = frac[0:digits]
+	}
+	frac += strings.Repeat("0", digits-len(frac))
+	fracI := 0
+	if digits > 0 {
+		fracI, err = strconv.Atoi(frac)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %s: %v", s, err)
+		}
+	}
+
+	pow := 1
+	for i := 0; i < digits; i++ {
+		pow *= 10
+	}
+	out := whole*pow + fracI
+	if negative {
+		out = -out
+	}
+	return out, nil
+}
+
+// precisionOf returns 3 if any amount uses a third decimal digit that isn't zero, otherwise 2
+func precisionOf(amounts ...string) int {
+	for _, a := range amounts {
+		a = strings.ReplaceAll(strings.TrimSpace(a), ",", ".")
+		i := strings.Index(a, ".")
+		if i < 0 {
+			continue
+		}
+		frac := strings.TrimRight(a[i+1:], "0")
+		if len(frac) >= 3 {
+			return 3
+		}
+	}
+	return 2
+}
+
+func formatAmount(d int, precision int) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	pow := 100
+	if precision == 3 {
+		pow = 1000
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, d/pow, precision, d%pow)
+}
+
+// formatDate converts an ISO 8601 date to dd/mm/yyyy
+func formatDate(s string) string {
+	t, err := time.Parse("2006-01-02", strings.TrimSpace(s))
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return t.Format("02/01/2006")
+}
@@ -0,0 +1,170 @@
+package einvoice
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// ublDocument is the subset of a UBL 2.1 Invoice or CreditNote (including Peppol BIS Billing 3.0) needed for the
+// VAT form.  Elements are matched by local name so the cac/cbc namespace prefixes don't matter.
+type ublDocument struct {
+	XMLName   xml.Name
+	ID        string `xml:"ID"`
+	IssueDate string `xml:"IssueDate"`
+	// 380 is a commercial invoice, 381 a credit note.  Credit notes usually have their own root element but
+	// some senders use an Invoice with type code 381 instead.
+	InvoiceTypeCode      string `xml:"InvoiceTypeCode"`
+	CreditNoteTypeCode   string `xml:"CreditNoteTypeCode"`
+	DocumentCurrencyCode string `xml:"DocumentCurrencyCode"`
+	Supplier             struct {
+		Name      string `xml:"PartyName>Name"`
+		TaxScheme []struct {
+			CompanyID string `xml:"CompanyID"`
+		} `xml:"PartyTaxScheme"`
+		LegalEntity struct {
+			RegistrationName string `xml:"RegistrationName"`
+			CompanyID        string `xml:"CompanyID"`
+		} `xml:"PartyLegalEntity"`
+	} `xml:"AccountingSupplierParty>Party"`
+	TaxTotals []ublTaxTotal `xml:"TaxTotal"`
+	Totals    struct {
+		TaxInclusiveAmount ublAmount `xml:"TaxInclusiveAmount"`
+		PayableAmount      ublAmount `xml:"PayableAmount"`
+	} `xml:"LegalMonetaryTotal"`
+	InvoiceLines    []ublLine `xml:"InvoiceLine"`
+	CreditNoteLines []ublLine `xml:"CreditNoteLine"`
+}
+
+type ublAmount struct {
+	Value      string `xml:",chardata"`
+	CurrencyID string `xml:"currencyID,attr"`
+}
+
+type ublTaxTotal struct {
+	TaxAmount ublAmount `xml:"TaxAmount"`
+	Subtotals []struct {
+		TaxableAmount ublAmount `xml:"TaxableAmount"`
+		TaxAmount     ublAmount `xml:"TaxAmount"`
+		Percent       string    `xml:"TaxCategory>Percent"`
+	} `xml:"TaxSubtotal"`
+}
+
+type ublLine struct {
+	Name                string    `xml:"Item>Name"`
+	Description         string    `xml:"Item>Description"`
+	LineExtensionAmount ublAmount `xml:"LineExtensionAmount"`
+}
+
+func parseUBL(data []byte) ([]Invoice, error) {
+	var doc ublDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse UBL invoice: %v", err)
+	}
+
+	currency := strings.TrimSpace(doc.DocumentCurrencyCode)
+	if len(currency) == 0 {
+		currency = "EUR"
+	}
+
+	// there can be a second tax total in the accounting currency without subtotals, so use the one in the
+	// document currency
+	var tax ublTaxTotal
+	for i, t := range doc.TaxTotals {
+		id := strings.TrimSpace(t.TaxAmount.CurrencyID)
+		if i == 0 || len(id) == 0 || id == currency {
+			tax = t
+			if len(t.Subtotals) > 0 {
+				break
+			}
+		}
+	}
+
+	totalS := doc.Totals.TaxInclusiveAmount.Value
+	if len(strings.TrimSpace(totalS)) == 0 {
+		totalS = doc.Totals.PayableAmount.Value
+	}
+
+	amounts := []string{totalS, tax.TaxAmount.Value}
+	for _, s := range tax.Subtotals {
+		amounts = append(amounts, s.TaxableAmount.Value, s.TaxAmount.Value)
+	}
+	precision := precisionOf(amounts...)
+
+	total, err := parseAmount(totalS, precision)
+	if err != nil {
+		return nil, err
+	}
+	vat, err := parseAmount(tax.TaxAmount.Value, precision)
+	if err != nil {
+		return nil, err
+	}
+
+	var subtotals []TaxSubtotal
+	subtotalVAT := 0
+	for _, s := range tax.Subtotals {
+		taxable, err := parseAmount(s.TaxableAmount.Value, precision)
+		if err != nil {
+			return nil, err
+		}
+		v, err := parseAmount(s.TaxAmount.Value, precision)
+		if err != nil {
+			return nil, err
+		}
+		subtotalVAT += v
+		subtotals = append(subtotals, TaxSubtotal{Rate: strings.TrimSpace(s.Percent), Taxable: taxable, VAT: v})
+	}
+	// tax amount is mandatory in Peppol but not in plain UBL
+	if len(strings.TrimSpace(tax.TaxAmount.Value)) == 0 {
+		vat = subtotalVAT
+	}
+
+	lines := doc.InvoiceLines
+	if doc.XMLName.Local == "CreditNote" {
+		lines = doc.CreditNoteLines
+	}
+
+	// credit notes reduce the amount claimed
+	if doc.XMLName.Local == "CreditNote" || strings.TrimSpace(doc.InvoiceTypeCode) == "381" {
+		total, vat = -abs(total), -abs(vat)
+		for i := range subtotals {
+			subtotals[i].Taxable, subtotals[i].VAT = -abs(subtotals[i].Taxable), -abs(subtotals[i].VAT)
+		}
+	}
+
+	vendor := strings.TrimSpace(doc.Supplier.LegalEntity.RegistrationName)
+	if len(vendor) == 0 {
+		vendor = strings.TrimSpace(doc.Supplier.Name)
+	}
+	var vatNumber string
+	for _, s := range doc.Supplier.TaxScheme {
+		if id := strings.TrimSpace(s.CompanyID); len(id) > 0 {
+			vatNumber = id
+			break
+		}
+	}
+
+	invoice := Invoice{
+		Vendor:       vendor,
+		RegCode:      strings.TrimSpace(doc.Supplier.LegalEntity.CompanyID),
+		VATNumber:    vatNumber,
+		Number:       strings.TrimSpace(doc.ID),
+		Date:         formatDate(doc.IssueDate),
+		Total:        total,
+		VAT:          vat,
+		Precision:    precision,
+		Currency:     currency,
+		TaxSubtotals: subtotals,
+	}
+	for _, line := range lines {
+		desc := strings.TrimSpace(line.Name)
+		if len(desc) == 0 {
+			desc = strings.TrimSpace(line.Description)
+		}
+		invoice.Items = append(invoice.Items, Item{
+			Description: desc,
+			Amount:      strings.TrimSpace(line.LineExtensionAmount.Value),
+		})
+	}
+	return []Invoice{invoice}, nil
+}
@@ -0,0 +1,62 @@
+package einvoice
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUBL(t *testing.T) {
+	tt := []struct {
+		name      string
+		file      string
+		number    string
+		total     int
+		vat       int
+		subtotals []TaxSubtotal
+		items     []Item
+	}{
+		{
+			name:      "invoice",
+			file:      "testdata/ubl-invoice.xml",
+			number:    "PR-2023-0412",
+			total:     7090,
+			vat:       1090,
+			subtotals: []TaxSubtotal{{"20", 5000, 1000}, {"9", 1000, 90}},
+			items:     []Item{{"Kohvimasin", "50.00"}, {"Raamat", "10.00"}},
+		},
+		{
+			name:      "credit note",
+			file:      "testdata/ubl-creditnote.xml",
+			number:    "CN-77",
+			total:     -3000,
+			vat:       -500,
+			subtotals: []TaxSubtotal{{"20", -2500, -500}},
+			items:     []Item{{"Kohvimasin", "25.00"}},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := os.Open(tc.file)
+			require.NoError(t, err)
+			defer f.Close()
+
+			invoices, err := Parse(f)
+			require.NoError(t, err)
+			require.Len(t, invoices, 1)
+
+			inv := invoices[0]
+			assert.Equal(t, "Prisma Peremarket AS", inv.Vendor)
+			assert.Equal(t, "10000878", inv.RegCode)
+			assert.Equal(t, tc.number, inv.Number)
+			assert.Equal(t, tc.total, inv.Total)
+			assert.Equal(t, tc.vat, inv.VAT)
+			assert.Equal(t, 2, inv.Precision)
+			assert.Equal(t, "EUR", inv.Currency)
+			assert.Equal(t, tc.subtotals, inv.TaxSubtotals)
+			assert.Equal(t, tc.items, inv.Items)
+		})
+	}
+}
@@ -20,7 +20,10 @@ If you notice any problems, you can reply to this email for help.
 const errorEmail = `Hi {{.FormData.FirstName}},
 
 There was a problem processing your forms.  This email has also been sent to Bryan so he can fix it.  Sorry about that!
-
+{{if .Warnings}}
+Some of your photos may be part of the problem:
+{{range .Warnings}}  - {{.}}
+{{end}}{{end}}
 Run log:
 {{.RunLog}}
 `
@@ -31,6 +34,8 @@ type EmailData struct {
 	Year     int
 	Link     string
 	RunLog   string
+	// Warnings about photo quality, with the file name first
+	Warnings []string
 }
 
 type EmailService interface {
@@ -29,3 +29,27 @@ func TestErrorTemplate(t *testing.T) {
 	assert.Equal(t, expect, b.String())
 
 }
+
+const expectWarnings = `Hi Test,
+
+There was a problem processing your forms.  This email has also been sent to Bryan so he can fix it.  Sorry about that!
+
+Some of your photos may be part of the problem:
+  - receipt.jpg: photo is blurry
+  - other.jpg: photo is too dark
+
+Run log:
+This is a test
+`
+
+func TestErrorTemplateWarnings(t *testing.T) {
+	temp, err := template.New("email").Parse(errorEmail)
+	require.NoError(t, err)
+	var b bytes.Buffer
+	assert.NoError(t, temp.Execute(&b, EmailData{
+		FormData: FormData{FirstName: "Test"},
+		RunLog:   "This is a test",
+		Warnings: []string{"receipt.jpg: photo is blurry", "other.jpg: photo is too dark"},
+	}))
+	assert.Equal(t, expectWarnings, b.String())
+}
@@ -2,6 +2,8 @@ package vatinator
 
 import (
 	"encoding/json"
+
+	"github.com/BTBurke/vatinator/svc"
 )
 
 // FormData is the data needed to fill out the form top sections
@@ -14,6 +16,10 @@ type FormData struct {
 	// Embassy details
 	Embassy string `json:"embassy"`
 	Address string `json:"address"`
+	// Country is the code of the mission country, like USA, used in file names (default: USA)
+	Country string `json:"country,omitempty"`
+	// FileNames is a template for the names of the exported files (default: svc.DefaultFileNames)
+	FileNames string `json:"file_names,omitempty"`
 	// Bank for deposit
 	Bank     string `json:"bank"`
 	BankName string `json:"bank_name"`
@@ -30,6 +36,9 @@ func UnmarshalFormData(b []byte) (FormData, error) {
 	if err := json.Unmarshal(b, &fd); err != nil {
 		return FormData{}, err
 	}
+	if err := svc.ValidateFileNames(fd.FileNames); err != nil {
+		return FormData{}, err
+	}
 	return fd, nil
 }
 
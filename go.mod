@@ -55,6 +55,7 @@ require (
 	github.com/vmihailenco/tagparser v0.1.2 // indirect
 	golang.org/x/crypto v0.11.0
 	golang.org/x/image v0.9.0
+	golang.org/x/net v0.12.0
 	golang.org/x/text v0.11.0
 	google.golang.org/api v0.131.0
 	google.golang.org/appengine v1.6.7 // indirect
@@ -24,6 +24,12 @@ const (
 	png
 	zip
 	pdfFile
+	xmlFile
+	emlFile
+	mboxFile
+	heic
+	webp
+	tiff
 )
 
 func (ft fileType) String() string {
@@ -36,6 +42,18 @@ func (ft fileType) String() string {
 		return "zip"
 	case pdfFile:
 		return "pdf"
+	case xmlFile:
+		return "xml"
+	case emlFile:
+		return "eml"
+	case mboxFile:
+		return "mbox"
+	case heic:
+		return "heic"
+	case webp:
+		return "webp"
+	case tiff:
+		return "tiff"
 	default:
 		return ""
 	}
@@ -66,7 +84,15 @@ func FileAddHandler(basepath string) http.HandlerFunc {
 			return
 		}
 
+		// browsers send HEIC and mbox files as application/octet-stream or leave the content type empty
 		ftype := typeFromContentType(finfo.Header.Get("Content-Type"))
+		if ftype == unknown {
+			ftype = typeFromFilename(finfo.Filename)
+		}
+		if ftype == unknown {
+			handleError(w, http.StatusBadRequest, fmt.Errorf("unsupported file type: %s", finfo.Filename))
+			return
+		}
 
 		if err := storeFileContent(file, datapath, ftype); err != nil {
 			handleError(w, http.StatusInternalServerError, errors.Wrap(err, "error while writing uploaded image to storage"))
@@ -87,6 +113,45 @@ func typeFromContentType(ct string) fileType {
 		return zip
 	case "application/pdf":
 		return pdfFile
+	case "application/xml", "text/xml":
+		return xmlFile
+	case "message/rfc822":
+		return emlFile
+	case "application/mbox":
+		return mboxFile
+	case "image/heic", "image/heif", "image/heic-sequence", "image/heif-sequence":
+		return heic
+	case "image/webp":
+		return webp
+	case "image/tiff":
+		return tiff
+	default:
+		return unknown
+	}
+}
+
+func typeFromFilename(name string) fileType {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return jpg
+	case ".png":
+		return png
+	case ".zip":
+		return zip
+	case ".pdf":
+		return pdfFile
+	case ".xml":
+		return xmlFile
+	case ".eml":
+		return emlFile
+	case ".mbox":
+		return mboxFile
+	case ".heic", ".heif":
+		return heic
+	case ".webp":
+		return webp
+	case ".tif", ".tiff":
+		return tiff
 	default:
 		return unknown
 	}
@@ -156,25 +221,33 @@ func storeZipContent(r io.ReadCloser, datapath string) error {
 		if f.FileInfo.IsDir() {
 			return nil
 		}
-		switch {
-		case strings.HasSuffix(f.FileInfo.Name(), "jpg"):
-			return storeFileContent(f, datapath, jpg)
-		case strings.HasSuffix(f.FileInfo.Name(), "png"):
-			return storeFileContent(f, datapath, png)
-		default:
-			return fmt.Errorf("unknown filetype: %s", f.FileInfo.Name())
+		// skip anything that can't be a receipt, like .DS_Store, instead of failing the whole zip
+		ftype := typeFromFilename(f.FileInfo.Name())
+		if ftype == unknown || ftype == zip || strings.HasPrefix(f.FileInfo.Name(), "._") {
+			log.Printf("skipping unknown file in zip: %s", f.FileInfo.Name())
+			return nil
 		}
+		return storeFileContent(f, datapath, ftype)
 	})
 }
 
-// storePDF2ImgContent converts an input pdf to an image before storing it
+// storePDF2ImgContent converts an input pdf to images before storing them.  PDFs with one receipt per page are
+// stored as one image per page.
 func storePDF2ImgContent(r io.ReadCloser, datapath string) error {
-	rc, err := pdf.PdfToImage(r)
+	images, err := pdf.PdfToImages(r, pdf.PagesAuto)
 	if err != nil {
 		return errors.Wrap(err, "failed to convert uploaded pdf to image")
 	}
 
-	return storeFileContent(rc, datapath, png)
+	for i, rc := range images {
+		if err := storeFileContent(rc, datapath, png); err != nil {
+			for _, rest := range images[i+1:] {
+				rest.Close()
+			}
+			return err
+		}
+	}
+	return nil
 }
 
 func copyFile(to, from string) error {
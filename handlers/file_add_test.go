@@ -99,8 +99,59 @@ func createTestZip(filename string, nested bool) []string {
 		toArchive = append(toArchive, fname)
 		out = append(out, dataFile)
 	}
+	// files that aren't receipts are skipped
+	junk := filepath.Join(tmpdir, ".DS_Store")
+	if err := ioutil.WriteFile(junk, []byte("junk"), 0644); err != nil {
+		panic(err)
+	}
+	toArchive = append(toArchive, junk)
+
 	if err := archiver.NewZip().Archive(toArchive, filename); err != nil {
 		panic(err)
 	}
 	return out
 }
+
+func TestTypeFromContentType(t *testing.T) {
+	tt := []struct {
+		in  string
+		out fileType
+	}{
+		{"image/jpeg", jpg},
+		{"image/png", png},
+		{"application/zip", zip},
+		{"application/pdf", pdfFile},
+		{"application/xml", xmlFile},
+		{"text/xml", xmlFile},
+		{"message/rfc822", emlFile},
+		{"image/heic", heic},
+		{"image/webp", webp},
+		{"image/tiff", tiff},
+		{"application/mbox", mboxFile},
+		{"text/plain", unknown},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.out, typeFromContentType(tc.in), tc.in)
+	}
+}
+
+func TestTypeFromFilename(t *testing.T) {
+	tt := []struct {
+		in  string
+		out fileType
+	}{
+		{"IMG_0001.HEIC", heic},
+		{"receipt.heif", heic},
+		{"scan.tif", tiff},
+		{"scan.TIFF", tiff},
+		{"photo.webp", webp},
+		{"photo.JPEG", jpg},
+		{"arve.xml", xmlFile},
+		{"inbox.mbox", mboxFile},
+		{".DS_Store", unknown},
+		{"notes.txt", unknown},
+	}
+	for _, tc := range tt {
+		assert.Equal(t, tc.out, typeFromFilename(tc.in), tc.in)
+	}
+}
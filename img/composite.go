@@ -8,10 +8,10 @@ import (
 
 	"github.com/nfnt/resize"
 	"golang.org/x/image/font"
-	"golang.org/x/image/font/inconsolata"
 	"golang.org/x/image/math/fixed"
 )
 
+// HeaderHeight is the minimum height of the header, it grows for larger HeaderFontSize
 const HeaderHeight int = 20
 const HeaderText string = "Kviitung %d"
 
@@ -19,36 +19,129 @@ const HeaderText string = "Kviitung %d"
 const ImageMaxHeight int = 9 * 96
 const ImageMaxWidth int = 6.5 * 96
 
+// StampColor is used by CompositeReceipt, see StampOptions for CompositeReceiptWithOptions
 var StampColor color.RGBA = color.RGBA{125, 3, 8, 125}
 
 // CompositeReceipt will create a composite image of the receipt appropriately scaled to fit on the page,
 // with a header showing the receipt number, and the superimposed stamp.  If the stamp is empty or nil, no stamp
 // is applied.  If stampY is 0, it will be placed in a default position about 1/3 down the receipt.
 func CompositeReceipt(num int, receipt Image, stamp []string, stampY int) (Image, error) {
+	receipt, _, err := fitPage(receipt)
+	if err != nil {
+		return receipt, err
+	}
+	stampImg := createStamp(stamp)
+	if stampY == 0 {
+		// default place 1/3 down the page
+		stampY = receipt.Bounds().Max.Y / 3
+	}
+	stampLeft := (max(receipt.Bounds().Max.X, stampImg.Bounds().Max.X) - stampImg.Bounds().Max.X) / 2
+	return composite(num, receipt, stampImg, image.Pt(stampLeft, stampY-stampImg.Bounds().Max.Y), false)
+}
 
-	// Check receipt and resize to make it fit on letter size paper at 72 dpi
-	rcptWidth := receipt.Bounds().Max.X
-	rcptHeight := receipt.Bounds().Max.Y
-	if rcptHeight > ImageMaxHeight {
-		var err error
-		receipt, err = NewImageFromImage(resize.Resize(0, uint(ImageMaxHeight), receipt, resize.Lanczos3))
-		if err != nil {
-			return receipt, err
+// CompositeReceiptWithOptions is like CompositeReceipt but keeps the stamp off the text.  Text is the boxes of
+// the text on the receipt in pixels of the stored image.  The stamp goes in the largest area without text, or in
+// a margin added below the receipt when there is no room.  If placement is not nil it is used instead, so that
+// a receipt that was exported before looks the same.  It returns the placement used.
+func CompositeReceiptWithOptions(num int, receipt Image, stamp []string, opts *StampOptions, text []image.Rectangle, placement *StampPlacement) (Image, *StampPlacement, error) {
+	if opts == nil {
+		opts = &DefaultStampOptions
+	}
+	receipt, scale, err := fitPage(receipt)
+	if err != nil {
+		return receipt, nil, err
+	}
+	stampImg := createStampWithOptions(stamp, *opts)
+	if len(stamp) == 0 {
+		out, err := composite(num, receipt, stampImg, image.Point{}, false)
+		return out, placement, err
+	}
+
+	if placement == nil {
+		scaled := make([]image.Rectangle, 0, len(text))
+		for _, t := range text {
+			scaled = append(scaled, image.Rect(int(float64(t.Min.X)*scale), int(float64(t.Min.Y)*scale), int(float64(t.Max.X)*scale+0.5), int(float64(t.Max.Y)*scale+0.5)))
 		}
-		rcptWidth = receipt.Bounds().Max.X
-		rcptHeight = receipt.Bounds().Max.Y
-	}
-	if rcptWidth > ImageMaxWidth {
-		var err error
-		receipt, err = NewImageFromImage(resize.Resize(uint(ImageMaxWidth), 0, receipt, resize.Lanczos3))
-		if err != nil {
-			return receipt, err
+		pt, ok := placeStamp(receipt.Bounds(), scaled, stampImg.Bounds().Dx(), stampImg.Bounds().Dy())
+		placement = &StampPlacement{
+			X:      int(float64(pt.X)/scale + 0.5),
+			Y:      int(float64(pt.Y)/scale + 0.5),
+			Margin: !ok,
 		}
-		rcptWidth = receipt.Bounds().Max.X
-		rcptHeight = receipt.Bounds().Max.Y
 	}
 
-	stampImg := createStamp(stamp)
+	pt := image.Pt(int(float64(placement.X)*scale+0.5), int(float64(placement.Y)*scale+0.5))
+	if placement.Margin {
+		pt = image.Pt((max(receipt.Bounds().Dx(), stampImg.Bounds().Dx())-stampImg.Bounds().Dx())/2, receipt.Bounds().Dy())
+	}
+	out, err := composite(num, receipt, stampImg, pt, placement.Margin)
+	return out, placement, err
+}
+
+// fitPage scales the receipt down to fit on letter size paper at 96 dpi and returns the scale that was applied
+func fitPage(receipt Image) (Image, float64, error) {
+	scale := fitScale(receipt.Bounds())
+	if scale == 1 {
+		return receipt, scale, nil
+	}
+	w := uint(float64(receipt.Bounds().Max.X)*scale + 0.5)
+	out, err := NewImageFromImage(resize.Resize(w, 0, receipt, resize.Lanczos3))
+	if err != nil {
+		return receipt, scale, err
+	}
+	return out, float64(out.Bounds().Max.Y) / float64(receipt.Bounds().Max.Y), nil
+}
+
+// fitScale is the scale that makes the receipt fit within ImageMaxWidth x ImageMaxHeight
+func fitScale(b image.Rectangle) float64 {
+	scale := 1.0
+	if b.Max.Y > ImageMaxHeight {
+		scale = float64(ImageMaxHeight) / float64(b.Max.Y)
+	}
+	if float64(b.Max.X)*scale > float64(ImageMaxWidth) {
+		scale = float64(ImageMaxWidth) / float64(b.Max.X)
+	}
+	return scale
+}
+
+// Word is a word found by OCR and its box in pixels of the receipt image
+type Word struct {
+	Text string
+	Box  image.Rectangle
+}
+
+// Boxes returns just the boxes of the words
+func Boxes(words []Word) []image.Rectangle {
+	out := make([]image.Rectangle, 0, len(words))
+	for _, w := range words {
+		out = append(out, w.Box)
+	}
+	return out
+}
+
+// CompositeWords moves words on the stored receipt image to where they end up on the composite made by
+// CompositeReceipt or CompositeReceiptWithOptions, which is scaled to fit and shifted down by the header
+func CompositeWords(receipt Image, words []Word) []Word {
+	scale := fitScale(receipt.Bounds())
+	top := createHeader(0, 0).Bounds().Dy()
+	out := make([]Word, 0, len(words))
+	for _, w := range words {
+		out = append(out, Word{
+			Text: w.Text,
+			Box: image.Rect(
+				int(float64(w.Box.Min.X)*scale+0.5), int(float64(w.Box.Min.Y)*scale+0.5)+top,
+				int(float64(w.Box.Max.X)*scale+0.5), int(float64(w.Box.Max.Y)*scale+0.5)+top,
+			),
+		})
+	}
+	return out
+}
+
+// composite puts the header above the receipt and draws the stamp with its top left at stampAt in receipt
+// coordinates.  With margin, white space is added below the receipt for the stamp.
+func composite(num int, receipt Image, stampImg *image.RGBA, stampAt image.Point, margin bool) (Image, error) {
+	rcptWidth := receipt.Bounds().Max.X
+	rcptHeight := receipt.Bounds().Max.Y
 	stampWidth := stampImg.Bounds().Max.X
 	stampHeight := stampImg.Bounds().Max.Y
 
@@ -65,59 +158,59 @@ func CompositeReceipt(num int, receipt Image, stamp []string, stampY int) (Image
 		finalWidth = stampWidth
 	}
 	finalHeight := rcptHeight + headerHeight
-
-	// padding for stamp
-	if stampY == 0 {
-		// default place 1/3 down the page
-		stampY = rcptHeight / 3
+	if margin {
+		finalHeight += stampHeight
 	}
-	stampLeft := (finalWidth - stampWidth) / 2
-	stampTop := headerHeight + stampY - stampHeight
+
+	stampLeft := stampAt.X
+	stampTop := headerHeight + stampAt.Y
 	if stampTop < 0 {
 		stampTop = 0
 	}
 
+	// white under everything, JPEG has no transparency so any part of the canvas left uncovered would be black
 	img := image.NewRGBA(image.Rect(0, 0, finalWidth, finalHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
 	draw.Draw(img, image.Rect(0, 0, headerWidth, headerHeight), headerImg, image.Point{0, 0}, draw.Src)
-	draw.Draw(img, image.Rect(0, headerHeight, finalWidth, finalHeight), receipt, image.Point{0, 0}, draw.Src)
+	draw.Draw(img, image.Rect(0, headerHeight, rcptWidth, headerHeight+rcptHeight), receipt, image.Point{0, 0}, draw.Src)
 	draw.Draw(img, image.Rect(stampLeft, stampTop, stampLeft+stampWidth, stampTop+stampHeight), stampImg, image.Point{0, 0}, draw.Over)
 
-	return NewImageFromImage(img)
+	// JPEG keeps the invoice packet small, the PDF embeds it as is
+	return NewJPEGFromImage(img, DefaultStorage.Quality)
 }
 
 // createStamp creates a minimum size stamp with the text given in lines with a transparent background
 // and text color given by StampColor.  If lines is empty, it will return a zero pixel image.
 func createStamp(lines []string) *image.RGBA {
-	if len(lines) == 0 {
-		return image.NewRGBA(image.Rect(0, 0, 0, 0))
-	}
-	// number of pixels on top and bottom to account for characters that
-	// hang below the baseline
-	topBtmPad := 5
+	return renderStamp(lines, StampColor, 16)
+}
 
-	// lineheight set slightly smaller than text size based on trial and error
-	lineHeight := 14
+// createStampWithOptions is like createStamp with the color, opacity and size from opts
+func createStampWithOptions(lines []string, opts StampOptions) *image.RGBA {
+	return renderStamp(lines, opts.color(), opts.FontSize)
+}
 
-	max := 0
-	for _, line := range lines {
-		if len(line) > max {
-			max = len(line)
-		}
+func renderStamp(lines []string, c color.Color, size int) *image.RGBA {
+	if len(lines) == 0 {
+		return image.NewRGBA(image.Rect(0, 0, 0, 0))
 	}
+	face := newFace(float64(size))
+	defer face.Close()
 
-	w := max * 8
-	h := (len(lines) * lineHeight) + 2*topBtmPad
+	// small pad on the sides so the first and last glyphs aren't clipped
+	pad := 2
+	w, ascent, descent, lineHeight := measure(face, lines)
+	h := ascent + (len(lines)-1)*lineHeight + descent
 
-	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	img := image.NewRGBA(image.Rect(0, 0, w+2*pad, h))
 	d := &font.Drawer{
 		Dst:  img,
-		Src:  image.NewUniform(StampColor),
-		Face: inconsolata.Bold8x16,
+		Src:  image.NewUniform(c),
+		Face: face,
 	}
 
 	for i, line := range lines {
-		point := fixed.Point26_6{Y: fixed.Int26_6(((lineHeight * (i + 1)) + topBtmPad) * 64)}
-		d.Dot = point
+		d.Dot = fixed.P(pad, ascent+i*lineHeight)
 		d.DrawString(line)
 	}
 	return img
@@ -125,10 +218,17 @@ func createStamp(lines []string) *image.RGBA {
 
 // createHeader creates a header above the receipt using HeaderText as the format
 // string.  The width, w, determines the maximum width, but it will grow automatically to
-// fit the entire header text plus 8 pixels if it is too small.
+// fit the entire header text plus 8 pixels if it is too small.  The header is at least HeaderHeight tall and
+// grows with HeaderFontSize.
 func createHeader(num int, w int) *image.RGBA {
 	text := fmt.Sprintf(HeaderText, num)
-	textWidth := len(text) * 8
+	face := newFace(HeaderFontSize)
+	defer face.Close()
+	textWidth, ascent, descent, _ := measure(face, []string{text})
+	height := HeaderHeight
+	if ascent+descent+2 > height {
+		height = ascent + descent + 2
+	}
 
 	// if label wider than receipt, then make header minimum width
 	leftPad := (w - textWidth) / 2
@@ -137,17 +237,14 @@ func createHeader(num int, w int) *image.RGBA {
 		leftPad = 4
 	}
 
-	img := image.NewRGBA(image.Rect(0, 0, w, HeaderHeight))
-	for i := 0; i < w; i++ {
-		for j := 0; j < HeaderHeight; j++ {
-			img.Set(i, j, color.RGBA{0, 0, 0, 255})
-		}
-	}
+	img := image.NewRGBA(image.Rect(0, 0, w, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.RGBA{0, 0, 0, 255}}, image.Point{}, draw.Src)
 	d := &font.Drawer{
 		Dst:  img,
 		Src:  image.NewUniform(color.RGBA{255, 255, 255, 255}),
-		Face: inconsolata.Bold8x16,
-		Dot:  fixed.Point26_6{X: fixed.Int26_6(leftPad * 64), Y: fixed.Int26_6(16 * 64)},
+		Face: face,
+		// center the text vertically
+		Dot: fixed.P(leftPad, (height-ascent-descent)/2+ascent),
 	}
 	d.DrawString(text)
 
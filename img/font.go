@@ -0,0 +1,55 @@
+package img
+
+import (
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/opentype"
+)
+
+// HeaderFontSize is the size in pixels of the "Kviitung N" header text
+var HeaderFontSize float64 = 14
+
+var (
+	parseFont sync.Once
+	textFont  *opentype.Font
+)
+
+// newFace returns a face at size pixels.  Go Mono Bold is embedded in the binary and covers Latin, Cyrillic and
+// Greek, so names and addresses with õ, ä, ö, ü, š or ž render correctly.  Faces aren't safe for concurrent use,
+// so each caller gets its own.
+func newFace(size float64) font.Face {
+	parseFont.Do(func() {
+		var err error
+		textFont, err = opentype.Parse(gomonobold.TTF)
+		if err != nil {
+			// the font is compiled in, so this can only happen if it was corrupted
+			panic(err)
+		}
+	})
+	if size <= 0 {
+		size = 16
+	}
+	face, err := opentype.NewFace(textFont, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+// measure returns the width of the widest line and the ascent, descent and line height of the face, all in
+// whole pixels
+func measure(face font.Face, lines []string) (width, ascent, descent, lineHeight int) {
+	for _, line := range lines {
+		if w := font.MeasureString(face, line).Ceil(); w > width {
+			width = w
+		}
+	}
+	m := face.Metrics()
+	return width, m.Ascent.Ceil(), m.Descent.Ceil(), m.Height.Ceil()
+}
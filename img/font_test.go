@@ -0,0 +1,47 @@
+package img
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ink(i *image.RGBA) int {
+	n := 0
+	for p := 3; p < len(i.Pix); p += 4 {
+		if i.Pix[p] > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func TestStampUnicode(t *testing.T) {
+	// width is measured in glyphs, not bytes
+	ascii := createStamp([]string{"Oun Saz"})
+	estonian := createStamp([]string{"Õun Šäž"})
+	assert.Equal(t, ascii.Bounds(), estonian.Bounds())
+
+	// accented letters get their own glyphs instead of a missing glyph box
+	plain := createStamp([]string{"o"})
+	accent := createStamp([]string{"õ"})
+	assert.Greater(t, ink(accent), ink(plain))
+
+	// bigger font, bigger stamp
+	big := createStampWithOptions([]string{"Oun Saz"}, StampOptions{FontSize: 32})
+	assert.Greater(t, big.Bounds().Dx(), ascii.Bounds().Dx()*3/2)
+	assert.Greater(t, big.Bounds().Dy(), ascii.Bounds().Dy()*3/2)
+}
+
+func TestHeaderSize(t *testing.T) {
+	h := createHeader(12, 300)
+	assert.Equal(t, image.Rect(0, 0, 300, HeaderHeight), h.Bounds())
+
+	// too narrow for the text so it grows
+	assert.Greater(t, createHeader(12, 10).Bounds().Dx(), 10)
+
+	defer func(size float64) { HeaderFontSize = size }(HeaderFontSize)
+	HeaderFontSize = 30
+	assert.Greater(t, createHeader(12, 300).Bounds().Dy(), HeaderHeight)
+}
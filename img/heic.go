@@ -0,0 +1,56 @@
+package img
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// brands in the ftyp box that mean the file is HEIC/HEIF
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true, "hevc": true, "hevx": true,
+	"mif1": true, "msf1": true,
+}
+
+// isHEIF checks the ISO base media file header, because image.Decode doesn't know about HEIC
+func isHEIF(b []byte) bool {
+	if len(b) < 16 || string(b[4:8]) != "ftyp" {
+		return false
+	}
+	return heifBrands[string(b[8:12])]
+}
+
+// decodeHEIF converts a HEIC/HEIF image to JPEG with heif-convert from libheif.  Only the primary image is used.
+func decodeHEIF(b []byte) (Image, error) {
+	bin, err := exec.LookPath("heif-convert")
+	if err != nil {
+		return Image{}, errors.Wrap(err, "requires heif-convert (libheif) to read HEIC images")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "heic")
+	if err != nil {
+		return Image{}, errors.Wrap(err, "failed to create tempdir for heic conversion")
+	}
+	defer os.RemoveAll(tmpdir)
+
+	in := filepath.Join(tmpdir, "in.heic")
+	out := filepath.Join(tmpdir, "out.jpg")
+	if err := ioutil.WriteFile(in, b, 0600); err != nil {
+		return Image{}, errors.Wrap(err, "failed to write heic tmpfile")
+	}
+	cmd := exec.Command(bin, "-q", "95", in, out)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return Image{}, errors.Wrapf(err, "failed to convert heic image with output: %s", output)
+	}
+
+	jpg, err := ioutil.ReadFile(out)
+	if err != nil {
+		return Image{}, errors.Wrap(err, "failed to read converted heic image")
+	}
+	return NewImageFromReader(bytes.NewReader(jpg))
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/BTBurke/vatinator/db"
+	_ "golang.org/x/image/webp"
 )
 
 type Image struct {
@@ -20,12 +21,33 @@ type Image struct {
 	image  image.Image
 }
 
+// NewImageFromBytes decodes JPEG, PNG, GIF and WebP directly, turning JPEGs upright according to their EXIF
+// orientation.  HEIC and TIFF are converted to another format first because the Vision API doesn't accept HEIC
+// and only reads the first page of a TIFF.
 func NewImageFromBytes(b []byte) (Image, error) {
+	switch {
+	case isHEIF(b):
+		return decodeHEIF(b)
+	case isTIFF(b):
+		i, err := decodeTIFF(b)
+		if err != nil {
+			return Image{}, err
+		}
+		return NewImageFromImage(i)
+	}
+
 	img, f, err := image.Decode(bytes.NewReader(b))
 	if err != nil {
 		return Image{}, err
 	}
 
+	// photos from phones are stored sideways with an EXIF tag that says how to display them
+	if f == "jpeg" {
+		if o := exifOrientation(bytes.NewReader(b)); o > 1 && o <= 8 {
+			return NewImageFromImage(orient(img, o))
+		}
+	}
+
 	return Image{
 		b:      b,
 		format: f,
@@ -58,6 +80,11 @@ func (i Image) GetImage() image.Image {
 	return i.image
 }
 
+// Format is the encoding of the image bytes, like "png" or "jpeg"
+func (i Image) Format() string {
+	return i.format
+}
+
 func encodePNG(i image.Image) ([]byte, error) {
 	var b bytes.Buffer
 	if err := png.Encode(&b, i); err != nil {
@@ -126,7 +153,14 @@ func (i *Image) Type() byte {
 }
 
 func (i *Image) MarshalBinary() ([]byte, error) {
-	return i.AsPNG()
+	b, _, err := i.MarshalFormat()
+	return b, err
+}
+
+// MarshalFormat encodes the image according to DefaultStorage and returns the format actually used
+func (i *Image) MarshalFormat() ([]byte, byte, error) {
+	b, f, err := i.Encode(DefaultStorage.Format, DefaultStorage.Quality)
+	return b, byte(f), err
 }
 
 func (i *Image) UnmarshalBinary(data []byte) error {
@@ -138,5 +172,14 @@ func (i *Image) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+func (i *Image) UnmarshalFormat(data []byte, format byte) error {
+	img, err := decodeFormat(data, Format(format))
+	if err != nil {
+		return err
+	}
+	*i = img
+	return nil
+}
+
 var _ image.Image = Image{}
-var _ db.Entity = &Image{}
+var _ db.Formatted = &Image{}
@@ -2,9 +2,11 @@ package img
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"image/jpeg"
 	"image/png"
 
 	"os"
@@ -148,3 +150,89 @@ func TestRotateCCW(t *testing.T) {
 // })
 // }
 // }
+
+// withOrientation inserts an EXIF segment with just the orientation tag after the JPEG start of image marker
+func withOrientation(jpg []byte, orientation byte) []byte {
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, 0, 0, 0, 0}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	n := len(payload) + 2
+	app1 := append([]byte{0xff, 0xe1, byte(n >> 8), byte(n)}, payload...)
+
+	out := append([]byte{}, jpg[0:2]...)
+	out = append(out, app1...)
+	return append(out, jpg[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	// 32x16 with the top left quarter black so every orientation moves it somewhere different
+	src := image.NewGray(image.Rect(0, 0, 32, 16))
+	draw.Draw(src, src.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	draw.Draw(src, image.Rect(0, 0, 16, 8), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	var b bytes.Buffer
+	require.NoError(t, jpeg.Encode(&b, src, &jpeg.Options{Quality: 100}))
+
+	tt := []struct {
+		orientation byte
+		bounds      image.Rectangle
+		// where the black quarter ends up
+		black image.Point
+	}{
+		{1, image.Rect(0, 0, 32, 16), image.Point{4, 4}},
+		{2, image.Rect(0, 0, 32, 16), image.Point{28, 4}},
+		{3, image.Rect(0, 0, 32, 16), image.Point{28, 12}},
+		{4, image.Rect(0, 0, 32, 16), image.Point{4, 12}},
+		{5, image.Rect(0, 0, 16, 32), image.Point{4, 4}},
+		{6, image.Rect(0, 0, 16, 32), image.Point{12, 4}},
+		{7, image.Rect(0, 0, 16, 32), image.Point{12, 28}},
+		{8, image.Rect(0, 0, 16, 32), image.Point{4, 28}},
+	}
+	for _, tc := range tt {
+		t.Run(fmt.Sprintf("orientation %d", tc.orientation), func(t *testing.T) {
+			data := withOrientation(b.Bytes(), tc.orientation)
+			original := append([]byte{}, data...)
+
+			i, err := NewImageFromBytes(data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.bounds, i.Bounds())
+			r, _, _, _ := i.At(tc.black.X, tc.black.Y).RGBA()
+			assert.True(t, r < 0x4000, "expected black at %v", tc.black)
+			assert.Equal(t, original, data)
+		})
+	}
+}
+
+func TestRotateByExifWithoutExif(t *testing.T) {
+	i, err := NewImageFromImage(image.NewGray(image.Rect(0, 0, 4, 2)))
+	require.NoError(t, err)
+	out, err := RotateByExif(i)
+	assert.NoError(t, err)
+	assert.Equal(t, i.Bounds(), out.Bounds())
+}
+
+func TestStorageFormat(t *testing.T) {
+	p := whitePage(200, 100)
+	i, err := NewImageFromImage(p)
+	require.NoError(t, err)
+
+	// png bytes are reused as is
+	b, f, err := i.Encode(PNG, 0)
+	require.NoError(t, err)
+	assert.Equal(t, PNG, f)
+	orig, _ := i.AsPNG()
+	assert.Equal(t, orig, b)
+
+	b, f, err = i.Encode(JPEG, 80)
+	require.NoError(t, err)
+	assert.Equal(t, JPEG, f)
+	var out Image
+	require.NoError(t, out.UnmarshalFormat(b, byte(f)))
+	assert.Equal(t, "jpeg", out.Format())
+	assert.Equal(t, i.Bounds(), out.Bounds())
+
+	for _, s := range []string{"png", "JPG", "jpeg", "webp"} {
+		_, err := ParseFormat(s)
+		assert.NoError(t, err, s)
+	}
+	_, err = ParseFormat("bmp")
+	assert.Error(t, err)
+}
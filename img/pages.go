@@ -0,0 +1,114 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+// TrimFuzz is how far a pixel can be from the background color, as a fraction of full intensity, and still be
+// trimmed.  It ignores faint anti-aliasing and scanner noise at the edges.
+var TrimFuzz = 0.05
+
+// Trim removes the border that is the same color as the top left pixel, like ImageMagick -trim.  An image that
+// is entirely background is returned unchanged.
+func Trim(i image.Image) image.Image {
+	b := i.Bounds()
+	if b.Empty() {
+		return i
+	}
+	bg := i.At(b.Min.X, b.Min.Y)
+	limit := uint32(TrimFuzz * 0xffff)
+
+	isBackground := func(x, y int) bool {
+		return colorDistance(i.At(x, y), bg) <= limit
+	}
+	rowIsBackground := func(y int) bool {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !isBackground(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+	colIsBackground := func(x, top, bottom int) bool {
+		for y := top; y < bottom; y++ {
+			if !isBackground(x, y) {
+				return false
+			}
+		}
+		return true
+	}
+
+	top, bottom := b.Min.Y, b.Max.Y
+	for top < bottom && rowIsBackground(top) {
+		top++
+	}
+	if top == bottom {
+		return i
+	}
+	for bottom > top && rowIsBackground(bottom-1) {
+		bottom--
+	}
+	left, right := b.Min.X, b.Max.X
+	for left < right && colIsBackground(left, top, bottom) {
+		left++
+	}
+	for right > left && colIsBackground(right-1, top, bottom) {
+		right--
+	}
+	return imaging.Crop(i, image.Rect(left, top, right, bottom))
+}
+
+// colorDistance is the largest difference in any channel
+func colorDistance(c1, c2 color.Color) uint32 {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	d := uint32(0)
+	for _, pair := range [][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+		diff := pair[0] - pair[1]
+		if pair[1] > pair[0] {
+			diff = pair[1] - pair[0]
+		}
+		if diff > d {
+			d = diff
+		}
+	}
+	return d
+}
+
+// RotateCW90 rotates the image 90 degrees clockwise, like ImageMagick -rotate 90
+func RotateCW90(i image.Image) image.Image {
+	return imaging.Rotate270(i)
+}
+
+// Append stacks the pages vertically on a white background, left aligned, like ImageMagick -append
+func Append(pages []image.Image) image.Image {
+	w, h := 0, 0
+	for _, p := range pages {
+		if p.Bounds().Dx() > w {
+			w = p.Bounds().Dx()
+		}
+		h += p.Bounds().Dy()
+	}
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(out, out.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	y := 0
+	for _, p := range pages {
+		r := image.Rect(0, y, p.Bounds().Dx(), y+p.Bounds().Dy())
+		draw.Draw(out, r, p, p.Bounds().Min, draw.Over)
+		y += p.Bounds().Dy()
+	}
+	return out
+}
+
+// FitHeight scales the image down to at most max pixels tall, keeping the aspect ratio
+func FitHeight(i image.Image, max int) image.Image {
+	if max <= 0 || i.Bounds().Dy() <= max {
+		return i
+	}
+	return imaging.Resize(i, 0, max, imaging.Lanczos)
+}
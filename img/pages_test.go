@@ -0,0 +1,57 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func whitePage(w, h int) *image.RGBA {
+	p := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(p, p.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+	return p
+}
+
+func TestTrim(t *testing.T) {
+	p := whitePage(100, 50)
+	draw.Draw(p, image.Rect(20, 10, 30, 40), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	// faint noise is inside the fuzz and gets trimmed
+	p.Set(90, 45, color.RGBA{250, 250, 250, 255})
+
+	out := Trim(p)
+	assert.Equal(t, 10, out.Bounds().Dx())
+	assert.Equal(t, 30, out.Bounds().Dy())
+
+	blank := whitePage(10, 10)
+	assert.Equal(t, blank.Bounds(), Trim(blank).Bounds())
+}
+
+func TestRotateCW90(t *testing.T) {
+	p := whitePage(20, 10)
+	p.Set(0, 0, color.Black)
+
+	out := RotateCW90(p)
+	assert.Equal(t, image.Rect(0, 0, 10, 20), out.Bounds())
+	// top left moves to top right when rotating clockwise
+	r, _, _, _ := out.At(9, 0).RGBA()
+	assert.Equal(t, uint32(0), r)
+}
+
+func TestAppend(t *testing.T) {
+	p1 := image.NewGray(image.Rect(0, 0, 20, 10))
+	p2 := image.NewGray(image.Rect(0, 0, 10, 15))
+
+	out := Append([]image.Image{p1, p2})
+	assert.Equal(t, image.Rect(0, 0, 20, 25), out.Bounds())
+	// the narrower page leaves white space on the right
+	assert.Equal(t, color.RGBA{255, 255, 255, 255}, out.At(15, 20))
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, out.At(5, 20))
+}
+
+func TestFitHeight(t *testing.T) {
+	assert.Equal(t, image.Rect(0, 0, 50, 100), FitHeight(whitePage(100, 200), 100).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 100, 200), FitHeight(whitePage(100, 200), 300).Bounds())
+}
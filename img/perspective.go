@@ -0,0 +1,279 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+)
+
+// Quad is the outline of a receipt in a photo.  Corners are in pixels of the original image, clockwise from
+// the top left.
+type Quad struct {
+	TopLeft     image.Point
+	TopRight    image.Point
+	BottomRight image.Point
+	BottomLeft  image.Point
+}
+
+// size of the copy used to find the receipt outline
+const detectSize = 512
+
+// Limits for accepting a detected outline.  A receipt that fills nearly the whole frame is already flat (a scan or
+// a tight crop) and warping it can only lose text.  An outline that doesn't fill its own quadrilateral is a
+// crumpled receipt or something else on the table, so it's safer to leave the photo alone.
+const (
+	minDocumentArea = 0.10
+	maxDocumentArea = 0.90
+	minQuadFill     = 0.85
+)
+
+// DetectDocument finds the outline of the receipt as the largest bright area of the photo.  It returns false
+// when there is no clear outline.
+func DetectDocument(i Image) (Quad, bool) {
+	src := i.GetImage()
+	if src == nil || src.Bounds().Empty() {
+		return Quad{}, false
+	}
+	small := src
+	if src.Bounds().Dx() > detectSize || src.Bounds().Dy() > detectSize {
+		small = imaging.Fit(src, detectSize, detectSize, imaging.Box)
+	}
+	// blur away the print so the receipt is one solid bright area
+	g := toGray(imaging.Blur(small, 2))
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+
+	blob, area := largestBright(g, otsuThreshold(g))
+	frac := float64(area) / float64(w*h)
+	if frac < minDocumentArea || frac > maxDocumentArea {
+		return Quad{}, false
+	}
+
+	// the corners are the points furthest along each diagonal
+	var q Quad
+	first := true
+	for p, in := range blob {
+		if !in {
+			continue
+		}
+		pt := image.Pt(p%w, p/w)
+		if first {
+			q = Quad{pt, pt, pt, pt}
+			first = false
+			continue
+		}
+		if pt.X+pt.Y < q.TopLeft.X+q.TopLeft.Y {
+			q.TopLeft = pt
+		}
+		if pt.X+pt.Y > q.BottomRight.X+q.BottomRight.Y {
+			q.BottomRight = pt
+		}
+		if pt.X-pt.Y > q.TopRight.X-q.TopRight.Y {
+			q.TopRight = pt
+		}
+		if pt.X-pt.Y < q.BottomLeft.X-q.BottomLeft.Y {
+			q.BottomLeft = pt
+		}
+	}
+	if !q.convex() || float64(area)/q.area() < minQuadFill {
+		return Quad{}, false
+	}
+
+	sx := float64(src.Bounds().Dx()) / float64(w)
+	sy := float64(src.Bounds().Dy()) / float64(h)
+	scale := func(p image.Point) image.Point {
+		return image.Pt(int(float64(p.X)*sx+0.5), int(float64(p.Y)*sy+0.5))
+	}
+	return Quad{scale(q.TopLeft), scale(q.TopRight), scale(q.BottomRight), scale(q.BottomLeft)}, true
+}
+
+// largestBright returns a mask of the largest 4-connected area brighter than threshold and its size in pixels
+func largestBright(g *image.Gray, threshold int) ([]bool, int) {
+	w, h := g.Bounds().Dx(), g.Bounds().Dy()
+	label := make([]int, w*h)
+	best, bestArea := 0, 0
+	next := 0
+	var stack []int
+	for start := 0; start < w*h; start++ {
+		if label[start] != 0 || int(g.Pix[(start/w)*g.Stride+start%w]) <= threshold {
+			continue
+		}
+		next++
+		label[start] = next
+		stack = append(stack[:0], start)
+		area := 0
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			area++
+			x, y := p%w, p/w
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= w || n[1] >= h {
+					continue
+				}
+				np := n[1]*w + n[0]
+				if label[np] == 0 && int(g.Pix[n[1]*g.Stride+n[0]]) > threshold {
+					label[np] = next
+					stack = append(stack, np)
+				}
+			}
+		}
+		if area > bestArea {
+			best, bestArea = next, area
+		}
+	}
+	mask := make([]bool, w*h)
+	for p, l := range label {
+		mask[p] = l == best && best != 0
+	}
+	return mask, bestArea
+}
+
+func (q Quad) points() [4]image.Point {
+	return [4]image.Point{q.TopLeft, q.TopRight, q.BottomRight, q.BottomLeft}
+}
+
+// area with the shoelace formula
+func (q Quad) area() float64 {
+	p := q.points()
+	var a float64
+	for i := range p {
+		j := (i + 1) % 4
+		a += float64(p[i].X*p[j].Y - p[j].X*p[i].Y)
+	}
+	return math.Abs(a) / 2
+}
+
+// convex is true when every turn around the corners goes the same way
+func (q Quad) convex() bool {
+	p := q.points()
+	sign := 0
+	for i := range p {
+		a, b, c := p[i], p[(i+1)%4], p[(i+2)%4]
+		cross := (b.X-a.X)*(c.Y-b.Y) - (b.Y-a.Y)*(c.X-b.X)
+		switch {
+		case cross == 0:
+			return false
+		case sign == 0 && cross > 0:
+			sign = 1
+		case sign == 0:
+			sign = -1
+		case (cross > 0) != (sign > 0):
+			return false
+		}
+	}
+	return true
+}
+
+func dist(a, b image.Point) float64 {
+	return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+}
+
+// Warp maps the quadrilateral to a flat rectangle.  The size of the rectangle is the longer of each pair of
+// opposite sides.
+func Warp(i Image, q Quad) (Image, error) {
+	src := imaging.Clone(i.GetImage())
+	w := int(math.Max(dist(q.TopLeft, q.TopRight), dist(q.BottomLeft, q.BottomRight)) + 0.5)
+	h := int(math.Max(dist(q.TopLeft, q.BottomLeft), dist(q.TopRight, q.BottomRight)) + 0.5)
+	if w < 2 || h < 2 {
+		return Image{}, errors.New("document outline is too small to warp")
+	}
+
+	dst := [4][2]float64{{0, 0}, {float64(w - 1), 0}, {float64(w - 1), float64(h - 1)}, {0, float64(h - 1)}}
+	var from [4][2]float64
+	for n, p := range q.points() {
+		from[n] = [2]float64{float64(p.X), float64(p.Y)}
+	}
+	m, err := homography(dst, from)
+	if err != nil {
+		return Image{}, err
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fx, fy := float64(x), float64(y)
+			d := m[6]*fx + m[7]*fy + 1
+			sx := (m[0]*fx + m[1]*fy + m[2]) / d
+			sy := (m[3]*fx + m[4]*fy + m[5]) / d
+			out.SetNRGBA(x, y, bilinear(src, sx, sy))
+		}
+	}
+	return NewImageFromImage(out)
+}
+
+// Flatten detects the receipt outline and warps it flat.  Photos without a clear outline are returned unchanged
+// with false.
+func Flatten(i Image) (Image, bool, error) {
+	q, ok := DetectDocument(i)
+	if !ok {
+		return i, false, nil
+	}
+	out, err := Warp(i, q)
+	if err != nil {
+		return i, false, err
+	}
+	return out, true, nil
+}
+
+// homography solves for the 8 coefficients of the projective transform that maps each from point to the
+// matching to point
+func homography(from, to [4][2]float64) ([8]float64, error) {
+	var a [8][9]float64
+	for n := 0; n < 4; n++ {
+		u, v := from[n][0], from[n][1]
+		x, y := to[n][0], to[n][1]
+		a[2*n] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*n+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+
+	// gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for r := col + 1; r < 8; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9 {
+			return [8]float64{}, errors.New("document outline is degenerate")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		for r := 0; r < 8; r++ {
+			if r == col {
+				continue
+			}
+			f := a[r][col] / a[col][col]
+			for c := col; c < 9; c++ {
+				a[r][c] -= f * a[col][c]
+			}
+		}
+	}
+	var m [8]float64
+	for r := 0; r < 8; r++ {
+		m[r] = a[r][8] / a[r][r]
+	}
+	return m, nil
+}
+
+// bilinear samples src between pixels, clamping at the edges
+func bilinear(src *image.NRGBA, x, y float64) color.NRGBA {
+	b := src.Bounds()
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	at := func(x, y int) []uint8 {
+		x, y = clamp(x, 0, b.Dx()-1), clamp(y, 0, b.Dy()-1)
+		o := y*src.Stride + x*4
+		return src.Pix[o : o+4]
+	}
+	p00, p10, p01, p11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+	var c [4]uint8
+	for n := range c {
+		top := float64(p00[n])*(1-fx) + float64(p10[n])*fx
+		bottom := float64(p01[n])*(1-fx) + float64(p11[n])*fx
+		c[n] = uint8(top*(1-fy) + bottom*fy + 0.5)
+	}
+	return color.NRGBA{R: c[0], G: c[1], B: c[2], A: c[3]}
+}
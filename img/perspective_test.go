@@ -0,0 +1,78 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// photo of a white receipt on a dark table, with a black mark in the receipt's top left corner
+func tablePhoto(q Quad) *image.RGBA {
+	p := image.NewRGBA(image.Rect(0, 0, 800, 600))
+	draw.Draw(p, p.Bounds(), &image.Uniform{color.RGBA{60, 50, 40, 255}}, image.Point{}, draw.Src)
+	pts := q.points()
+	inside := func(x, y int) bool {
+		for i := range pts {
+			a, b := pts[i], pts[(i+1)%4]
+			if (b.X-a.X)*(y-a.Y)-(b.Y-a.Y)*(x-a.X) < 0 {
+				return false
+			}
+		}
+		return true
+	}
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 800; x++ {
+			if inside(x, y) {
+				p.Set(x, y, color.White)
+			}
+		}
+	}
+	draw.Draw(p, image.Rect(q.TopLeft.X+15, q.TopLeft.Y+15, q.TopLeft.X+45, q.TopLeft.Y+45), &image.Uniform{color.Black}, image.Point{}, draw.Src)
+	return p
+}
+
+func near(t *testing.T, want, got image.Point) {
+	assert.InDelta(t, want.X, got.X, 8, "x of %v", want)
+	assert.InDelta(t, want.Y, got.Y, 8, "y of %v", want)
+}
+
+func TestFlatten(t *testing.T) {
+	// narrower at the top like a receipt photographed at an angle
+	q := Quad{image.Pt(300, 100), image.Pt(520, 110), image.Pt(600, 520), image.Pt(220, 500)}
+	i, err := NewImageFromImage(tablePhoto(q))
+	require.NoError(t, err)
+
+	found, ok := DetectDocument(i)
+	require.True(t, ok)
+	near(t, q.TopLeft, found.TopLeft)
+	near(t, q.TopRight, found.TopRight)
+	near(t, q.BottomRight, found.BottomRight)
+	near(t, q.BottomLeft, found.BottomLeft)
+
+	flat, warped, err := Flatten(i)
+	require.NoError(t, err)
+	assert.True(t, warped)
+	b := flat.GetImage().Bounds()
+	assert.InDelta(t, 380, b.Dx(), 15)
+	assert.InDelta(t, 420, b.Dy(), 15)
+
+	// no table left in the corners, and the mark stays in the top left
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(flat.GetImage().At(x, y)).(color.Gray).Y }
+	assert.Greater(t, int(gray(b.Dx()-5, b.Dy()-5)), 200)
+	assert.Greater(t, int(gray(b.Dx()-5, 5)), 200)
+	assert.Less(t, int(gray(30, 30)), 60)
+}
+
+func TestFlattenLeavesScans(t *testing.T) {
+	// a receipt that fills the frame has no outline to find
+	i, err := NewImageFromImage(whitePage(400, 600))
+	require.NoError(t, err)
+	out, warped, err := Flatten(i)
+	require.NoError(t, err)
+	assert.False(t, warped)
+	assert.Equal(t, i, out)
+}
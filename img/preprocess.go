@@ -0,0 +1,221 @@
+package img
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// PreprocessOptions control how an image is prepared before it is sent for OCR.  The original image is still
+// used for cropping and the invoice packet.
+type PreprocessOptions struct {
+	// MaxDimension scales the image down so the longest side is at most this many pixels.  Zero keeps the full size.
+	MaxDimension int
+	// Grayscale drops color, which Vision doesn't need to read text
+	Grayscale bool
+	// Denoise runs a 3x3 median filter to remove speckle from photos of thermal paper
+	Denoise bool
+	// Contrast stretches the darkest and lightest 1% of pixels to black and white to bring back faded print
+	Contrast bool
+	// Threshold converts to black and white by comparing each pixel to the average of its neighborhood, which
+	// handles shadows and uneven lighting better than a single cutoff
+	Threshold bool
+	// JPEGQuality for the image sent to Vision when it isn't thresholded (default: 90)
+	JPEGQuality int
+}
+
+// DefaultPreprocessOptions are a good balance for phone photos.  Thresholding is off by default because it can
+// erase light print on good photos.
+var DefaultPreprocessOptions = PreprocessOptions{
+	MaxDimension: 2048,
+	Grayscale:    true,
+	Contrast:     true,
+	JPEGQuality:  90,
+}
+
+// Scale is the factor that maps coordinates in the preprocessed image back to the original
+type Scale struct {
+	X float64
+	Y float64
+}
+
+// Point maps a point in the preprocessed image to the original image
+func (s Scale) Point(x, y int32) (int32, int32) {
+	return int32(float64(x)*s.X + 0.5), int32(float64(y)*s.Y + 0.5)
+}
+
+// thresholdWindow is the fraction of the shorter side used as the neighborhood for adaptive thresholding, and
+// thresholdOffset is how much darker than the neighborhood average a pixel has to be to count as ink
+const thresholdWindow = 1.0 / 16
+const thresholdOffset = 0.15
+
+// Preprocess prepares the image for OCR according to opts.  A nil opts returns the image unchanged.
+func Preprocess(i Image, opts *PreprocessOptions) (Image, Scale, error) {
+	one := Scale{X: 1, Y: 1}
+	if opts == nil {
+		return i, one, nil
+	}
+
+	var out image.Image = i.GetImage()
+	b := out.Bounds()
+	changed := false
+
+	if opts.MaxDimension > 0 && (b.Dx() > opts.MaxDimension || b.Dy() > opts.MaxDimension) {
+		out = imaging.Fit(out, opts.MaxDimension, opts.MaxDimension, imaging.Lanczos)
+		changed = true
+	}
+
+	if opts.Grayscale || opts.Denoise || opts.Contrast || opts.Threshold {
+		g := toGray(out)
+		if opts.Denoise {
+			g = median3(g)
+		}
+		if opts.Contrast {
+			stretch(g)
+		}
+		if opts.Threshold {
+			g = adaptiveThreshold(g)
+		}
+		out = g
+		changed = true
+	}
+
+	if !changed {
+		return i, one, nil
+	}
+
+	scale := Scale{
+		X: float64(b.Dx()) / float64(out.Bounds().Dx()),
+		Y: float64(b.Dy()) / float64(out.Bounds().Dy()),
+	}
+
+	// black and white compresses well as PNG, everything else is smaller as JPEG
+	if opts.Threshold {
+		p, err := NewImageFromImage(out)
+		return p, scale, err
+	}
+	quality := opts.JPEGQuality
+	if quality <= 0 {
+		quality = 90
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: quality}); err != nil {
+		return Image{}, one, err
+	}
+	return Image{b: buf.Bytes(), format: "jpeg", image: out}, scale, nil
+}
+
+func toGray(i image.Image) *image.Gray {
+	b := i.Bounds()
+	g := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			g.Set(x, y, color.GrayModel.Convert(i.At(b.Min.X+x, b.Min.Y+y)))
+		}
+	}
+	return g
+}
+
+// median3 replaces each pixel with the median of its 3x3 neighborhood, clamping at the edges
+func median3(g *image.Gray) *image.Gray {
+	b := g.Bounds()
+	out := image.NewGray(b)
+	var window [9]uint8
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					window[n] = g.GrayAt(clamp(x+dx, 0, b.Dx()-1), clamp(y+dy, 0, b.Dy()-1)).Y
+					n++
+				}
+			}
+			s := window[:]
+			sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+			out.SetGray(x, y, color.Gray{Y: window[4]})
+		}
+	}
+	return out
+}
+
+// stretch maps the 1st and 99th percentile of brightness to black and white in place
+func stretch(g *image.Gray) {
+	var hist [256]int
+	for _, p := range g.Pix {
+		hist[p]++
+	}
+	total := len(g.Pix)
+	if total == 0 {
+		return
+	}
+
+	low, high := 0, 255
+	for n := 0; low < 255; low++ {
+		n += hist[low]
+		if n > total/100 {
+			break
+		}
+	}
+	for n := 0; high > 0; high-- {
+		n += hist[high]
+		if n > total/100 {
+			break
+		}
+	}
+	if high <= low {
+		return
+	}
+
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = uint8(clamp((v-low)*255/(high-low), 0, 255))
+	}
+	for i, p := range g.Pix {
+		g.Pix[i] = lut[p]
+	}
+}
+
+// adaptiveThreshold is Bradley's method using an integral image so the cost doesn't depend on the window size
+func adaptiveThreshold(g *image.Gray) *image.Gray {
+	b := g.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	integral := make([]int64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row int64
+		for x := 0; x < w; x++ {
+			row += int64(g.GrayAt(x, y).Y)
+			integral[(y+1)*(w+1)+x+1] = integral[y*(w+1)+x+1] + row
+		}
+	}
+
+	half := int(float64(min(w, h))*thresholdWindow) / 2
+	if half < 4 {
+		half = 4
+	}
+
+	out := image.NewGray(b)
+	for y := 0; y < h; y++ {
+		y0, y1 := clamp(y-half, 0, h-1), clamp(y+half, 0, h-1)
+		for x := 0; x < w; x++ {
+			x0, x1 := clamp(x-half, 0, w-1), clamp(x+half, 0, w-1)
+			count := int64((x1 - x0 + 1) * (y1 - y0 + 1))
+			sum := integral[(y1+1)*(w+1)+x1+1] - integral[y0*(w+1)+x1+1] - integral[(y1+1)*(w+1)+x0] + integral[y0*(w+1)+x0]
+
+			v := uint8(255)
+			if float64(int64(g.GrayAt(x, y).Y)*count) <= float64(sum)*(1-thresholdOffset) {
+				v = 0
+			}
+			out.SetGray(x, y, color.Gray{Y: v})
+		}
+	}
+	return out
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,60 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreprocessScale(t *testing.T) {
+	i, err := NewImageFromImage(whitePage(4000, 3000))
+	require.NoError(t, err)
+
+	out, scale, err := Preprocess(i, &DefaultPreprocessOptions)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 2048, 1536), out.Bounds())
+	x, y := scale.Point(1024, 768)
+	assert.Equal(t, int32(2000), x)
+	assert.Equal(t, int32(1500), y)
+
+	// small images and empty options leave the image alone
+	same, scale, err := Preprocess(i, &PreprocessOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, i.Bounds(), same.Bounds())
+	assert.Equal(t, Scale{1, 1}, scale)
+}
+
+func TestPreprocessFadedPrint(t *testing.T) {
+	// light gray text on a paper background that gets darker to the right, like a shadow across a receipt
+	p := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	for x := 0; x < 200; x++ {
+		bg := uint8(240 - x/2)
+		draw.Draw(p, image.Rect(x, 0, x+1, 100), &image.Uniform{color.RGBA{bg, bg, bg, 255}}, image.Point{}, draw.Src)
+	}
+	ink := func(x int) color.RGBA {
+		v := uint8(240 - x/2 - 60)
+		return color.RGBA{v, v, v, 255}
+	}
+	for _, x := range []int{20, 180} {
+		draw.Draw(p, image.Rect(x, 40, x+6, 60), &image.Uniform{ink(x)}, image.Point{}, draw.Src)
+	}
+	// speckle that denoise should remove
+	p.Set(100, 10, color.Black)
+
+	i, err := NewImageFromImage(p)
+	require.NoError(t, err)
+	out, _, err := Preprocess(i, &PreprocessOptions{Grayscale: true, Denoise: true, Contrast: true, Threshold: true})
+	require.NoError(t, err)
+
+	gray := func(x, y int) uint8 { return color.GrayModel.Convert(out.At(x, y)).(color.Gray).Y }
+	// ink is black on both sides even though the ink on the right is darker than the paper on the left
+	assert.Equal(t, uint8(0), gray(22, 50))
+	assert.Equal(t, uint8(0), gray(182, 50))
+	assert.Equal(t, uint8(255), gray(5, 50))
+	assert.Equal(t, uint8(255), gray(195, 50))
+	assert.Equal(t, uint8(255), gray(100, 10))
+}
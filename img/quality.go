@@ -0,0 +1,248 @@
+package img
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Quality measures how usable a photo is for OCR.  It is computed on a downscaled grayscale copy, but
+// TextHeight is reported in pixels of the original image.
+type Quality struct {
+	// Sharpness is the variance of the Laplacian.  Blurry photos have few sharp edges and a low variance.
+	Sharpness float64
+	// TextHeight is the median height of a character in pixels, or 0 if no text was found
+	TextHeight int
+	// Dark and Light are the 1st and 99th percentile brightness (0-255).  Washed out photos have no dark pixels
+	// and underexposed photos have no light ones.
+	Dark  int
+	Light int
+	// Coverage is the fraction of the frame that is bright like paper
+	Coverage float64
+}
+
+// QualityOptions are the limits used to warn about or reject a photo
+type QualityOptions struct {
+	MinSharpness  float64
+	MinTextHeight int
+	// photos with a dark level above MaxDark are washed out, photos with a light level below MinLight are too dark
+	MaxDark     int
+	MinLight    int
+	MinCoverage float64
+	// Reject skips OCR for hopeless photos, which are far outside the limits
+	Reject bool
+}
+
+// DefaultQualityOptions warn but never reject
+var DefaultQualityOptions = QualityOptions{
+	MinSharpness:  100,
+	MinTextHeight: 10,
+	MaxDark:       160,
+	MinLight:      100,
+	MinCoverage:   0.25,
+}
+
+// size of the copy used for analysis, which keeps this fast on full resolution phone photos
+const qualitySize = 1024
+
+// AnalyzeQuality measures blur, text size, exposure and how much of the frame is receipt
+func AnalyzeQuality(i Image) Quality {
+	src := i.GetImage()
+	if src == nil || src.Bounds().Empty() {
+		return Quality{}
+	}
+	small := src
+	if src.Bounds().Dx() > qualitySize || src.Bounds().Dy() > qualitySize {
+		small = imaging.Fit(src, qualitySize, qualitySize, imaging.Box)
+	}
+	scale := float64(src.Bounds().Dy()) / float64(small.Bounds().Dy())
+	g := toGray(small)
+
+	var q Quality
+	q.Sharpness = laplacianVariance(g)
+	q.Dark, q.Light = percentiles(g)
+	q.Coverage = paperCoverage(g)
+	q.TextHeight = int(float64(medianGlyphHeight(adaptiveThreshold(g)))*scale + 0.5)
+	return q
+}
+
+// Warnings explains what is wrong with the photo and how to fix it
+func (q Quality) Warnings(opts *QualityOptions) []string {
+	if opts == nil {
+		opts = &DefaultQualityOptions
+	}
+	var out []string
+	if q.Sharpness < opts.MinSharpness {
+		out = append(out, fmt.Sprintf("photo is blurry (sharpness %.0f, want at least %.0f), hold the phone steady and tap the receipt to focus", q.Sharpness, opts.MinSharpness))
+	}
+	if q.TextHeight > 0 && q.TextHeight < opts.MinTextHeight {
+		out = append(out, fmt.Sprintf("text is too small to read (about %d px tall, want at least %d), move closer or use a higher resolution", q.TextHeight, opts.MinTextHeight))
+	}
+	if q.Dark > opts.MaxDark {
+		out = append(out, "photo is washed out, avoid the flash and direct light on thermal paper")
+	}
+	if q.Light < opts.MinLight {
+		out = append(out, "photo is too dark, take it in better light")
+	}
+	if q.Coverage < opts.MinCoverage {
+		out = append(out, fmt.Sprintf("receipt fills only %.0f%% of the photo, move closer or crop it", q.Coverage*100))
+	}
+	return out
+}
+
+// Hopeless is true when OCR has almost no chance of reading the photo
+func (q Quality) Hopeless(opts *QualityOptions) bool {
+	if opts == nil {
+		opts = &DefaultQualityOptions
+	}
+	return q.Sharpness < opts.MinSharpness/4 ||
+		(q.TextHeight > 0 && q.TextHeight < opts.MinTextHeight/2) ||
+		q.Light < opts.MinLight/2 ||
+		q.Light-q.Dark < 20
+}
+
+func laplacianVariance(g *image.Gray) float64 {
+	b := g.Bounds()
+	if b.Dx() < 3 || b.Dy() < 3 {
+		return 0
+	}
+	var sum, sumSq float64
+	n := 0
+	for y := 1; y < b.Dy()-1; y++ {
+		for x := 1; x < b.Dx()-1; x++ {
+			l := float64(int(g.GrayAt(x-1, y).Y) + int(g.GrayAt(x+1, y).Y) + int(g.GrayAt(x, y-1).Y) + int(g.GrayAt(x, y+1).Y) - 4*int(g.GrayAt(x, y).Y))
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	return sumSq/float64(n) - mean*mean
+}
+
+func percentiles(g *image.Gray) (int, int) {
+	var hist [256]int
+	for _, p := range g.Pix {
+		hist[p]++
+	}
+	total := len(g.Pix)
+	dark, light := 0, 255
+	for n := 0; dark < 255; dark++ {
+		n += hist[dark]
+		if n > total/100 {
+			break
+		}
+	}
+	for n := 0; light > 0; light-- {
+		n += hist[light]
+		if n > total/100 {
+			break
+		}
+	}
+	return dark, light
+}
+
+// paperCoverage splits pixels into dark and light with Otsu's method and returns the light fraction
+func paperCoverage(g *image.Gray) float64 {
+	if len(g.Pix) == 0 {
+		return 0
+	}
+	threshold := otsuThreshold(g)
+	light := 0
+	for _, p := range g.Pix {
+		if int(p) > threshold {
+			light++
+		}
+	}
+	return float64(light) / float64(len(g.Pix))
+}
+
+// otsuThreshold returns the brightness that best separates the pixels into a dark and a light class
+func otsuThreshold(g *image.Gray) int {
+	var hist [256]float64
+	for _, p := range g.Pix {
+		hist[p]++
+	}
+	total := float64(len(g.Pix))
+
+	var sumAll float64
+	for v, n := range hist {
+		sumAll += float64(v) * n
+	}
+	var sumBelow, countBelow, best float64
+	threshold := 0
+	for v := 0; v < 256; v++ {
+		countBelow += hist[v]
+		if countBelow == 0 {
+			continue
+		}
+		countAbove := total - countBelow
+		if countAbove == 0 {
+			break
+		}
+		sumBelow += float64(v) * hist[v]
+		meanBelow := sumBelow / countBelow
+		meanAbove := (sumAll - sumBelow) / countAbove
+		between := countBelow * countAbove * (meanBelow - meanAbove) * (meanBelow - meanAbove)
+		if between > best {
+			best = between
+			threshold = v
+		}
+	}
+	return threshold
+}
+
+// medianGlyphHeight finds the connected blobs of ink in a black and white image and returns the median height of
+// the ones shaped like characters.  Specks, lines and large dark areas like the table under the receipt are
+// ignored.
+func medianGlyphHeight(bw *image.Gray) int {
+	b := bw.Bounds()
+	w, h := b.Dx(), b.Dy()
+	seen := make([]bool, w*h)
+	maxHeight := h / 8
+
+	var heights []int
+	var stack []int
+	for start := 0; start < w*h; start++ {
+		if seen[start] || bw.Pix[(start/w)*bw.Stride+start%w] != 0 {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		top, bottom, left, right, area := h, 0, w, 0, 0
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := p%w, p/w
+			area++
+			top, bottom = min(top, y), max(bottom, y)
+			left, right = min(left, x), max(right, x)
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					n := ny*w + nx
+					if !seen[n] && bw.Pix[ny*bw.Stride+nx] == 0 {
+						seen[n] = true
+						stack = append(stack, n)
+					}
+				}
+			}
+		}
+
+		gh, gw := bottom-top+1, right-left+1
+		if gh < 3 || gh > maxHeight || gw > 3*gh || area < 4 {
+			continue
+		}
+		heights = append(heights, gh)
+	}
+	if len(heights) == 0 {
+		return 0
+	}
+	sort.Ints(heights)
+	return heights[len(heights)/2]
+}
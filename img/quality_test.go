@@ -0,0 +1,40 @@
+package img
+
+import (
+	"os"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuality(t *testing.T) {
+	f, err := os.Open("../test_receipts/PXL_20201002_163234312.jpg")
+	require.NoError(t, err)
+	defer f.Close()
+	photo, err := NewImageFromReader(f)
+	require.NoError(t, err)
+
+	q := AnalyzeQuality(photo)
+	assert.Empty(t, q.Warnings(nil))
+	assert.False(t, q.Hopeless(nil))
+	assert.InDelta(t, 20, q.TextHeight, 6)
+
+	blurry, err := NewImageFromImage(imaging.Blur(photo.GetImage(), 6))
+	require.NoError(t, err)
+	q = AnalyzeQuality(blurry)
+	require.Len(t, q.Warnings(nil), 1)
+	assert.Contains(t, q.Warnings(nil)[0], "blurry")
+	assert.True(t, q.Hopeless(nil))
+
+	dark, err := NewImageFromImage(imaging.AdjustBrightness(photo.GetImage(), -70))
+	require.NoError(t, err)
+	assert.Contains(t, AnalyzeQuality(dark).Warnings(nil), "photo is too dark, take it in better light")
+
+	// a tiny copy of the photo has text too small to read
+	tiny, err := NewImageFromImage(imaging.Resize(photo.GetImage(), 0, 1000, imaging.Lanczos))
+	require.NoError(t, err)
+	q = AnalyzeQuality(tiny)
+	assert.Less(t, q.TextHeight, DefaultQualityOptions.MinTextHeight)
+}
@@ -0,0 +1,43 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/inconsolata"
+	"golang.org/x/image/math/fixed"
+)
+
+// margin and line height in pixels for rendered text pages
+const renderMargin int = 24
+const renderLineHeight int = 18
+
+// RenderText draws lines of text in black on a white page.  It creates a page image for documents that don't
+// start out as an image, like e-invoices, so they can still be stamped and added to the invoice packet.
+func RenderText(lines []string) (Image, error) {
+	max := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > max {
+			max = n
+		}
+	}
+
+	w := max*8 + 2*renderMargin
+	h := len(lines)*renderLineHeight + 2*renderMargin
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{0, 0}, draw.Src)
+
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(color.Black),
+		Face: inconsolata.Regular8x16,
+	}
+	for i, line := range lines {
+		d.Dot = fixed.P(renderMargin, renderMargin+renderLineHeight*(i+1))
+		d.DrawString(line)
+	}
+	return NewImageFromImage(img)
+}
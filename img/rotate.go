@@ -1,10 +1,10 @@
 package img
 
 import (
-	"fmt"
 	"image"
-	"log"
+	"io"
 
+	"github.com/disintegration/imaging"
 	"github.com/rwcarlsen/goexif/exif"
 )
 
@@ -44,39 +44,56 @@ func mapPoint(x, y, width, height int, angle int) (x2 int, y2 int) {
 	return
 }
 
-// RotateByExif reads embedded EXIF data and rotates the image to the correct aspect
+// RotateByExif reads embedded EXIF data and transforms the image so that it displays upright, handling all eight
+// orientations including the mirrored ones.  Images without EXIF data or an orientation tag are returned
+// unchanged.  Only the decoded image changes, never the source file.
 func RotateByExif(i Image) (Image, error) {
 	r, err := i.NewReader()
 	if err != nil {
 		return Image{}, err
 	}
-	e, _ := exif.Decode(r)
+	orientation := exifOrientation(r)
+	if orientation <= 1 || orientation > 8 {
+		return i, nil
+	}
+	return NewImageFromImage(orient(i.GetImage(), orientation))
+}
 
-	oField, err := e.Get(exif.Orientation)
+// exifOrientation returns the orientation tag or 0 if there isn't one
+func exifOrientation(r io.Reader) int {
+	e, err := exif.Decode(r)
+	if err != nil || e == nil {
+		return 0
+	}
+	tag, err := e.Get(exif.Orientation)
 	if err != nil {
-		return Image{}, err
+		return 0
 	}
-
-	orientation, err := oField.Int(0)
+	orientation, err := tag.Int(0)
 	if err != nil {
-		return Image{}, err
+		return 0
 	}
-	log.Printf("orientation: %d", orientation)
+	return orientation
+}
 
+// orient undoes the transform described by the EXIF orientation
+func orient(i image.Image, orientation int) image.Image {
 	switch orientation {
-	case 1:
-		return i, nil
-	case 8:
-		return RotateCCW(i)
+	case 2:
+		return imaging.FlipH(i)
 	case 3:
-		i2, err := RotateCCW(i)
-		if err != nil {
-			return i2, err
-		}
-		return RotateCCW(i2)
+		return imaging.Rotate180(i)
+	case 4:
+		return imaging.FlipV(i)
+	case 5:
+		return imaging.Transpose(i)
 	case 6:
-		return RotateCW(i)
+		return imaging.Rotate270(i)
+	case 7:
+		return imaging.Transverse(i)
+	case 8:
+		return imaging.Rotate90(i)
 	default:
-		return Image{}, fmt.Errorf("unknown exif rotation: %d", orientation)
+		return i
 	}
 }
@@ -0,0 +1,90 @@
+package img
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// StampOptions set the look of the embassy stamp
+type StampOptions struct {
+	// Color of the stamp text, alpha is ignored
+	Color color.RGBA
+	// Opacity from 0 (invisible) to 1 (solid)
+	Opacity float64
+	// FontSize of the stamp text in pixels on the page
+	FontSize int
+}
+
+// DefaultStampOptions are a semi-transparent red that looks like an ink stamp
+var DefaultStampOptions = StampOptions{
+	Color:    color.RGBA{250, 6, 16, 255},
+	Opacity:  0.5,
+	FontSize: 16,
+}
+
+func (o StampOptions) color() color.NRGBA {
+	a := o.Opacity
+	if a <= 0 || a > 1 {
+		a = DefaultStampOptions.Opacity
+	}
+	return color.NRGBA{R: o.Color.R, G: o.Color.G, B: o.Color.B, A: uint8(a*255 + 0.5)}
+}
+
+// StampPlacement is where the stamp went on a receipt, as the top left corner in pixels of the stored receipt
+// image.  Margin means the receipt had no room and the stamp went in a margin added below it.  It is saved with
+// the receipt so that exporting again puts the stamp in the same place.
+type StampPlacement struct {
+	X      int  `json:",omitempty"`
+	Y      int  `json:",omitempty"`
+	Margin bool `json:",omitempty"`
+}
+
+// gap to keep between the stamp and any text, and the step between positions that are tried
+const (
+	stampClearance = 4
+	stampStep      = 4
+)
+
+// placeStamp finds the spot for a w x h stamp inside bounds that overlaps none of the text boxes and is furthest
+// from text and the edges, which is the middle of the largest empty area.  It returns false if nothing fits.
+// Ties go to the spot closest to a third of the way down, where the stamp used to be put.
+func placeStamp(bounds image.Rectangle, text []image.Rectangle, w, h int) (image.Point, bool) {
+	if w > bounds.Dx() || h > bounds.Dy() {
+		return image.Point{}, false
+	}
+	preferred := image.Pt(bounds.Min.X+(bounds.Dx()-w)/2, bounds.Min.Y+bounds.Dy()/3-h)
+
+	var best image.Point
+	bestScore, bestDist := -1, math.MaxInt64
+	for y := bounds.Min.Y; y+h <= bounds.Max.Y; y += stampStep {
+		for x := bounds.Min.X; x+w <= bounds.Max.X; x += stampStep {
+			r := image.Rect(x, y, x+w, y+h)
+			score := min(min(x-bounds.Min.X, bounds.Max.X-r.Max.X), min(y-bounds.Min.Y, bounds.Max.Y-r.Max.Y))
+			for _, t := range text {
+				d := rectDistance(r, t)
+				if d < stampClearance {
+					score = -1
+					break
+				}
+				score = min(score, d)
+			}
+			if score < 0 {
+				continue
+			}
+			dx, dy := x-preferred.X, y-preferred.Y
+			dist := dx*dx + dy*dy
+			if score > bestScore || (score == bestScore && dist < bestDist) {
+				best, bestScore, bestDist = image.Pt(x, y), score, dist
+			}
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// rectDistance is the gap between two rectangles, 0 when they touch or overlap
+func rectDistance(a, b image.Rectangle) int {
+	dx := max(0, max(b.Min.X-a.Max.X, a.Min.X-b.Max.X))
+	dy := max(0, max(b.Min.Y-a.Max.Y, a.Min.Y-b.Max.Y))
+	return int(math.Sqrt(float64(dx*dx + dy*dy)))
+}
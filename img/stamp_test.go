@@ -0,0 +1,103 @@
+package img
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlaceStamp(t *testing.T) {
+	bounds := image.Rect(0, 0, 300, 600)
+
+	// text everywhere except a gap between 300 and 450
+	var text []image.Rectangle
+	for y := 0; y < 600; y += 20 {
+		if y >= 300 && y < 450 {
+			continue
+		}
+		text = append(text, image.Rect(10, y, 290, y+12))
+	}
+	pt, ok := placeStamp(bounds, text, 120, 50)
+	require.True(t, ok)
+	stamp := image.Rect(pt.X, pt.Y, pt.X+120, pt.Y+50)
+	for _, r := range text {
+		assert.False(t, stamp.Overlaps(r), "stamp %v overlaps %v", stamp, r)
+	}
+	// middle of the gap
+	assert.InDelta(t, 350, pt.Y, 8)
+	assert.InDelta(t, 90, pt.X, 8)
+
+	// no room at all
+	_, ok = placeStamp(bounds, []image.Rectangle{image.Rect(0, 0, 300, 600)}, 120, 50)
+	assert.False(t, ok)
+
+	// stamp wider than the receipt
+	_, ok = placeStamp(image.Rect(0, 0, 100, 600), nil, 120, 50)
+	assert.False(t, ok)
+}
+
+func TestCompositeStampPlacement(t *testing.T) {
+	rcpt, err := NewImageFromImage(whitePage(400, 300))
+	require.NoError(t, err)
+	stamp := []string{"First Last", "Embassy"}
+
+	// the receipt is full of text so the stamp goes in a margin below it
+	full := []image.Rectangle{image.Rect(0, 0, 400, 300)}
+	out, placement, err := CompositeReceiptWithOptions(1, rcpt, stamp, nil, full, nil)
+	require.NoError(t, err)
+	require.NotNil(t, placement)
+	assert.True(t, placement.Margin)
+	assert.Greater(t, out.Bounds().Dy(), 300+HeaderHeight)
+
+	// a saved placement is used as is
+	saved := &StampPlacement{X: 10, Y: 20}
+	out, placement, err = CompositeReceiptWithOptions(1, rcpt, stamp, &StampOptions{FontSize: 32, Opacity: 1}, full, saved)
+	require.NoError(t, err)
+	assert.Equal(t, saved, placement)
+	assert.Equal(t, 300+HeaderHeight, out.Bounds().Dy())
+	inked := func(area image.Rectangle) int {
+		n := 0
+		for y := area.Min.Y; y < area.Max.Y; y++ {
+			for x := area.Min.X; x < area.Max.X; x++ {
+				if _, g, _, _ := out.At(x, y).RGBA(); g < 0x8000 {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	assert.Greater(t, inked(image.Rect(10, HeaderHeight+20, 200, HeaderHeight+80)), 0)
+	assert.Equal(t, 0, inked(image.Rect(250, HeaderHeight+150, 400, HeaderHeight+300)))
+}
+
+func TestCompositeWords(t *testing.T) {
+	// twice the max height so everything is scaled by half
+	rcpt, err := NewImageFromImage(whitePage(200, 2*ImageMaxHeight))
+	require.NoError(t, err)
+	words := []Word{{Text: "KOKKU", Box: image.Rect(20, 100, 120, 140)}}
+
+	out := CompositeWords(rcpt, words)
+	require.Len(t, out, 1)
+	assert.Equal(t, "KOKKU", out[0].Text)
+	assert.Equal(t, image.Rect(10, 50+HeaderHeight, 60, 70+HeaderHeight), out[0].Box)
+
+	composite, _, err := CompositeReceiptWithOptions(1, rcpt, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ImageMaxHeight+HeaderHeight, composite.Bounds().Dy())
+}
+
+func TestCompositeNarrowReceipt(t *testing.T) {
+	// the stamp and header are wider than the receipt, the rest of the canvas has to be white in the JPEG
+	rcpt, err := NewImageFromImage(whitePage(40, 600))
+	require.NoError(t, err)
+	out, err := CompositeReceipt(1, rcpt, []string{"First Last", "Embassy of the United States"}, 0)
+	require.NoError(t, err)
+	require.Greater(t, out.Bounds().Dx(), 40)
+
+	r, g, b, _ := out.At(out.Bounds().Dx()-1, out.Bounds().Dy()-1).RGBA()
+	assert.Greater(t, r, uint32(0xf000))
+	assert.Greater(t, g, uint32(0xf000))
+	assert.Greater(t, b, uint32(0xf000))
+}
@@ -0,0 +1,175 @@
+package img
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/webp"
+)
+
+// Format is the encoding of an image in the database.  It is saved in the entity metadata.
+type Format byte
+
+const (
+	// PNG is zero so images stored before the format was recorded still decode
+	PNG Format = iota
+	JPEG
+	WebP
+)
+
+func (f Format) String() string {
+	switch f {
+	case JPEG:
+		return "jpeg"
+	case WebP:
+		return "webp"
+	default:
+		return "png"
+	}
+}
+
+// ParseFormat reads a format name from config, like "jpeg" or "webp"
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "png":
+		return PNG, nil
+	case "jpg", "jpeg":
+		return JPEG, nil
+	case "webp":
+		return WebP, nil
+	default:
+		return PNG, errors.Errorf("unknown image format: %s", s)
+	}
+}
+
+// StorageOptions control how receipt images are saved to the database
+type StorageOptions struct {
+	Format Format
+	// Quality for JPEG and WebP, 1-100
+	Quality int
+}
+
+// DefaultStorage keeps receipt photos as JPEG, which is a fraction of the size of PNG and plenty for the invoice
+// packet and needs no external tools.  WebP is opt-in, it needs cwebp and falls back to JPEG without it.
+var DefaultStorage = StorageOptions{
+	Format:  JPEG,
+	Quality: 85,
+}
+
+// Encode returns the image in format f, reusing the original bytes when they are already in that format
+func (i Image) Encode(f Format, quality int) ([]byte, Format, error) {
+	if i.b != nil && i.format == f.String() {
+		return i.b, f, nil
+	}
+	switch f {
+	case JPEG:
+		b, err := encodeJPGQuality(i.image, quality)
+		return b, JPEG, err
+	case WebP:
+		b, err := encodeWebP(i.image, quality)
+		if err == errNoWebP {
+			b, err = encodeJPGQuality(i.image, quality)
+			return b, JPEG, err
+		}
+		return b, WebP, err
+	default:
+		b, err := encodePNG(i.image)
+		return b, PNG, err
+	}
+}
+
+// NewJPEGFromImage is like NewImageFromImage but encodes JPEG, which is much smaller for photos
+func NewJPEGFromImage(i image.Image, quality int) (Image, error) {
+	b, err := encodeJPGQuality(i, quality)
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{
+		b:      b,
+		format: JPEG.String(),
+		image:  i,
+	}, nil
+}
+
+func encodeJPGQuality(i image.Image, quality int) ([]byte, error) {
+	if quality <= 0 || quality > 100 {
+		quality = DefaultStorage.Quality
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, i, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+var errNoWebP = errors.New("requires cwebp to encode webp images")
+
+// HasWebPEncoder is true when cwebp is installed so that WebP storage won't fall back to JPEG
+func HasWebPEncoder() bool {
+	_, err := exec.LookPath("cwebp")
+	return err == nil
+}
+
+// encodeWebP uses cwebp from libwebp because there is no WebP encoder in the Go image packages
+func encodeWebP(i image.Image, quality int) ([]byte, error) {
+	bin, err := exec.LookPath("cwebp")
+	if err != nil {
+		return nil, errNoWebP
+	}
+	if quality <= 0 || quality > 100 {
+		quality = DefaultStorage.Quality
+	}
+
+	tmpdir, err := ioutil.TempDir("", "webp")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create tempdir for webp encoding")
+	}
+	defer os.RemoveAll(tmpdir)
+
+	in := filepath.Join(tmpdir, "in.png")
+	out := filepath.Join(tmpdir, "out.webp")
+	b, err := encodePNG(i)
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(in, b, 0600); err != nil {
+		return nil, errors.Wrap(err, "failed to write webp tmpfile")
+	}
+	cmd := exec.Command(bin, "-quiet", "-q", strconv.Itoa(quality), in, "-o", out)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to encode webp image with output: %s", output)
+	}
+	return ioutil.ReadFile(out)
+}
+
+// decodeFormat decodes stored bytes with the decoder for their format
+func decodeFormat(b []byte, f Format) (Image, error) {
+	var i image.Image
+	var err error
+	switch f {
+	case JPEG:
+		i, err = jpeg.Decode(bytes.NewReader(b))
+	case WebP:
+		i, err = webp.Decode(bytes.NewReader(b))
+	default:
+		i, err = png.Decode(bytes.NewReader(b))
+	}
+	if err != nil {
+		return Image{}, errors.Wrapf(err, "failed to decode stored %s image", f)
+	}
+	return Image{
+		b:      b,
+		format: f.String(),
+		image:  i,
+	}, nil
+}
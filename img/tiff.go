@@ -0,0 +1,65 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"github.com/pkg/errors"
+	"golang.org/x/image/tiff"
+)
+
+// TIFF files from scanners often have one page per receipt or one receipt across several pages.  Pages are
+// stacked vertically like multipage PDFs.
+const maxTIFFPages = 20
+
+func isTIFF(b []byte) bool {
+	return len(b) >= 8 && (string(b[0:4]) == "II*\x00" || string(b[0:4]) == "MM\x00*")
+}
+
+// decodeTIFF decodes every page in the file.  The tiff package only reads the first page, so each page is decoded
+// by pointing the header at that page's IFD.  Strip and tile offsets are absolute so the rest of the file doesn't
+// need to change.
+func decodeTIFF(b []byte) (image.Image, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if b[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	var offsets []uint32
+	seen := make(map[uint32]bool)
+	next := order.Uint32(b[4:8])
+	for next != 0 && !seen[next] && len(offsets) < maxTIFFPages {
+		if int(next)+2 > len(b) {
+			return nil, errors.New("invalid tiff: page offset out of range")
+		}
+		seen[next] = true
+		offsets = append(offsets, next)
+
+		n := int(order.Uint16(b[next : next+2]))
+		end := int(next) + 2 + 12*n
+		if end+4 > len(b) {
+			break
+		}
+		next = order.Uint32(b[end : end+4])
+	}
+	if len(offsets) == 0 {
+		return nil, errors.New("invalid tiff: no pages")
+	}
+
+	var pages []image.Image
+	page := make([]byte, len(b))
+	copy(page, b)
+	for i, off := range offsets {
+		order.PutUint32(page[4:8], off)
+		p, err := tiff.Decode(bytes.NewReader(page))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode tiff page %d", i+1)
+		}
+		pages = append(pages, p)
+	}
+	if len(pages) == 1 {
+		return pages[0], nil
+	}
+	return Append(pages), nil
+}
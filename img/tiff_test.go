@@ -0,0 +1,102 @@
+package img
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/tiff"
+)
+
+func TestDecodeTIFF(t *testing.T) {
+	page := image.NewGray(image.Rect(0, 0, 20, 10))
+	page.Set(5, 5, color.Black)
+
+	var b bytes.Buffer
+	require.NoError(t, tiff.Encode(&b, page, nil))
+
+	i, err := NewImageFromBytes(b.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 10), i.Bounds())
+	r, g, bl, _ := i.At(5, 5).RGBA()
+	assert.Equal(t, []uint32{0, 0, 0}, []uint32{r, g, bl})
+}
+
+func TestIsHEIF(t *testing.T) {
+	assert.True(t, isHEIF([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1")))
+	assert.False(t, isHEIF([]byte("\x00\x00\x00\x18ftypisom\x00\x00\x00\x00mp41")))
+	assert.False(t, isHEIF([]byte("short")))
+}
+
+func TestDecodeTIFFPages(t *testing.T) {
+	first := image.NewGray(image.Rect(0, 0, 20, 10))
+	first.Set(5, 5, color.Black)
+	second := image.NewGray(image.Rect(0, 0, 16, 15))
+	for i := range second.Pix {
+		second.Pix[i] = 0xff
+	}
+	second.Set(3, 4, color.Black)
+
+	i, err := NewImageFromBytes(multiPageTIFF(first, second))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 25), i.Bounds())
+	for _, pt := range []image.Point{{5, 5}, {3, 14}} {
+		r, g, bl, _ := i.At(pt.X, pt.Y).RGBA()
+		assert.Equal(t, []uint32{0, 0, 0}, []uint32{r, g, bl}, "pixel %v", pt)
+	}
+	// narrower second page is padded with white
+	r, g, bl, _ := i.At(18, 20).RGBA()
+	assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, bl})
+}
+
+// multiPageTIFF writes an uncompressed little endian grayscale TIFF with one page per image, like a scanner does
+func multiPageTIFF(pages ...*image.Gray) []byte {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	b.WriteString("II*\x00")
+	binary.Write(&b, le, uint32(0))
+
+	var prev int
+	for _, p := range pages {
+		w, h := p.Bounds().Dx(), p.Bounds().Dy()
+		data := b.Len()
+		for y := 0; y < h; y++ {
+			b.Write(p.Pix[y*p.Stride : y*p.Stride+w])
+		}
+		if b.Len()%2 == 1 {
+			b.WriteByte(0)
+		}
+
+		ifd := b.Len()
+		if prev == 0 {
+			le.PutUint32(b.Bytes()[4:8], uint32(ifd))
+		} else {
+			le.PutUint32(b.Bytes()[prev:prev+4], uint32(ifd))
+		}
+		entries := [][2]uint32{
+			{256, uint32(w)}, // width
+			{257, uint32(h)}, // height
+			{258, 8},         // bits per sample
+			{259, 1},         // no compression
+			{262, 1},         // black is zero
+			{273, uint32(data)},
+			{277, 1}, // samples per pixel
+			{278, uint32(h)},
+			{279, uint32(w * h)},
+		}
+		binary.Write(&b, le, uint16(len(entries)))
+		for _, e := range entries {
+			binary.Write(&b, le, uint16(e[0]))
+			binary.Write(&b, le, uint16(4)) // LONG
+			binary.Write(&b, le, uint32(1))
+			binary.Write(&b, le, e[1])
+		}
+		prev = b.Len()
+		binary.Write(&b, le, uint32(0))
+	}
+	return b.Bytes()
+}
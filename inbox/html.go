@@ -0,0 +1,96 @@
+package inbox
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// lines longer than this are wrapped so the rendered page isn't too wide
+const textWidth = 80
+
+// elements that start a new line in the text rendering
+var blockElements = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true, "div": true, "dd": true, "dl": true,
+	"dt": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"header": true, "hr": true, "li": true, "ol": true, "p": true, "pre": true, "section": true, "table": true,
+	"tbody": true, "thead": true, "tfoot": true, "tr": true, "ul": true,
+}
+
+// elements whose content is never visible
+var hiddenElements = map[string]bool{"head": true, "script": true, "style": true, "title": true, "template": true}
+
+// Text renders an HTML body as plain text lines.  Table cells on the same row stay on the same line so that
+// labels and amounts end up next to each other like they would on a paper receipt.
+func Text(data []byte) []string {
+	var lines []string
+	var current strings.Builder
+	hidden := 0
+
+	newLine := func() {
+		line := strings.Join(strings.Fields(current.String()), " ")
+		current.Reset()
+		if len(line) > 0 {
+			lines = append(lines, wrap(line, textWidth)...)
+		}
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(data))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			newLine()
+			return lines
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case hiddenElements[tag]:
+				hidden++
+			case blockElements[tag]:
+				newLine()
+			case tag == "td" || tag == "th":
+				current.WriteString("  ")
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			switch {
+			case hiddenElements[tag]:
+				if hidden > 0 {
+					hidden--
+				}
+			case blockElements[tag]:
+				newLine()
+			}
+		case html.TextToken:
+			if hidden == 0 {
+				current.Write(z.Text())
+			}
+		}
+	}
+}
+
+// wrap breaks a line on spaces so that no line is longer than width runes, unless a single word is longer
+func wrap(line string, width int) []string {
+	var out []string
+	var current []string
+	n := 0
+	for _, word := range strings.Fields(line) {
+		l := len([]rune(word))
+		if n > 0 && n+1+l > width {
+			out = append(out, strings.Join(current, " "))
+			current, n = nil, 0
+		}
+		if n > 0 {
+			n++
+		}
+		current = append(current, word)
+		n += l
+	}
+	if len(current) > 0 {
+		out = append(out, strings.Join(current, " "))
+	}
+	return out
+}
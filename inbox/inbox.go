@@ -0,0 +1,281 @@
+// Package inbox reads receipts out of email messages saved as RFC 822 .eml files or mbox archives
+package inbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Kind is the type of receipt document found in a message
+type Kind int
+
+const (
+	Unknown Kind = iota
+	PDF
+	JPEG
+	PNG
+	// HTML is the body of the message.  Use Text to get the lines of the receipt.
+	HTML
+)
+
+// Message is an email with the parts that might be receipts
+type Message struct {
+	From        string
+	Subject     string
+	Attachments []Attachment
+}
+
+// Attachment is a receipt document in the message.  Data is decoded from the transfer encoding.
+type Attachment struct {
+	Name string
+	Kind Kind
+	Data []byte
+}
+
+// ReadMessage parses a single RFC 822 message.  PDF and image attachments are returned in the order they appear.
+// The HTML body is only returned when the message has no PDF or image attachments, because then the email itself
+// is usually the receipt.
+func ReadMessage(r io.Reader) (Message, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return Message{}, fmt.Errorf("failed to read email message: %v", err)
+	}
+
+	out := Message{
+		From:    decodeAddress(msg.Header.Get("From")),
+		Subject: decodeHeader(msg.Header.Get("Subject")),
+	}
+
+	var bodies []Attachment
+	if err := walk(mimeHeader(msg.Header), msg.Body, &out.Attachments, &bodies); err != nil {
+		return Message{}, err
+	}
+	if len(out.Attachments) == 0 {
+		out.Attachments = bodies
+	}
+	return out, nil
+}
+
+// ReadMbox parses every message in an mbox archive.  Both the mboxo and mboxrd escaping of From lines are handled.
+func ReadMbox(r io.Reader) ([]Message, error) {
+	var out []Message
+	var current bytes.Buffer
+	inMessage := false
+	prevBlank := true
+
+	flush := func() error {
+		if !inMessage {
+			return nil
+		}
+		msg, err := ReadMessage(bytes.NewReader(current.Bytes()))
+		if err != nil {
+			return err
+		}
+		out = append(out, msg)
+		current.Reset()
+		return nil
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			switch {
+			case strings.HasPrefix(line, "From ") && prevBlank:
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				inMessage = true
+			case inMessage:
+				if unescaped := strings.TrimLeft(line, ">"); len(unescaped) < len(line) && strings.HasPrefix(unescaped, "From ") {
+					line = line[1:]
+				}
+				current.WriteString(line)
+			}
+			prevBlank = strings.TrimRight(line, "\r\n") == ""
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mbox: %v", err)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no messages found in mbox")
+	}
+	return out, nil
+}
+
+// header is the part of a MIME header needed to walk the message
+type header struct {
+	contentType string
+	encoding    string
+	disposition string
+}
+
+func mimeHeader(h map[string][]string) header {
+	get := func(key string) string {
+		if v := h[key]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	return header{
+		contentType: get("Content-Type"),
+		encoding:    get("Content-Transfer-Encoding"),
+		disposition: get("Content-Disposition"),
+	}
+}
+
+// walk recursively collects receipt attachments and HTML bodies from a MIME part
+func walk(h header, body io.Reader, attachments *[]Attachment, bodies *[]Attachment) error {
+	mediaType, params, err := mime.ParseMediaType(h.contentType)
+	if err != nil {
+		// RFC 2045 default
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read message part: %v", err)
+			}
+			if err := walk(mimeHeader(p.Header), p, attachments, bodies); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := ioutil.ReadAll(decodeTransfer(h.encoding, body))
+	if err != nil {
+		return fmt.Errorf("failed to decode message part: %v", err)
+	}
+
+	// forwarded messages keep their attachments
+	if mediaType == "message/rfc822" {
+		msg, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to read forwarded message: %v", err)
+		}
+		return walk(mimeHeader(msg.Header), msg.Body, attachments, bodies)
+	}
+
+	name := filename(h.disposition, params)
+	kind := kindOf(mediaType, name)
+	switch {
+	case kind == HTML && !strings.HasPrefix(strings.ToLower(h.disposition), "attachment"):
+		text, err := toUTF8(data, params["charset"])
+		if err != nil {
+			return err
+		}
+		if len(name) == 0 {
+			name = fmt.Sprintf("message-%d.html", len(*bodies)+1)
+		}
+		*bodies = append(*bodies, Attachment{Name: name, Kind: HTML, Data: text})
+	case kind == PDF || kind == JPEG || kind == PNG:
+		if len(name) == 0 {
+			name = fmt.Sprintf("attachment-%d", len(*attachments)+1)
+		}
+		*attachments = append(*attachments, Attachment{Name: name, Kind: kind, Data: data})
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// kindOf uses the content type, falling back to the file extension because mail clients often send attachments
+// as application/octet-stream
+func kindOf(mediaType string, name string) Kind {
+	switch mediaType {
+	case "application/pdf":
+		return PDF
+	case "image/jpeg", "image/jpg", "image/pjpeg":
+		return JPEG
+	case "image/png":
+		return PNG
+	case "text/html":
+		return HTML
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		return PDF
+	case ".jpg", ".jpeg":
+		return JPEG
+	case ".png":
+		return PNG
+	default:
+		return Unknown
+	}
+}
+
+func filename(disposition string, params map[string]string) string {
+	if _, dp, err := mime.ParseMediaType(disposition); err == nil && len(dp["filename"]) > 0 {
+		return decodeHeader(dp["filename"])
+	}
+	return decodeHeader(params["name"])
+}
+
+var wordDecoder = &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+
+func decodeHeader(s string) string {
+	d, err := wordDecoder.DecodeHeader(s)
+	if err != nil {
+		return strings.TrimSpace(s)
+	}
+	return strings.TrimSpace(d)
+}
+
+// decodeAddress returns the sender as "Name <address>" with the name decoded
+func decodeAddress(s string) string {
+	parser := &mail.AddressParser{WordDecoder: wordDecoder}
+	addr, err := parser.Parse(s)
+	if err != nil {
+		return decodeHeader(s)
+	}
+	if len(addr.Name) == 0 {
+		return addr.Address
+	}
+	return fmt.Sprintf("%s <%s>", addr.Name, addr.Address)
+}
+
+func toUTF8(data []byte, label string) ([]byte, error) {
+	if len(label) == 0 || strings.EqualFold(label, "utf-8") {
+		return data, nil
+	}
+	r, err := charset.NewReaderLabel(label, bytes.NewReader(data))
+	if err != nil {
+		// unknown charset, best effort
+		return data, nil
+	}
+	return ioutil.ReadAll(r)
+}
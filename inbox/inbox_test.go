@@ -0,0 +1,55 @@
+package inbox
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadMessage(t *testing.T) {
+	f, err := os.Open("testdata/receipt.eml")
+	require.NoError(t, err)
+	defer f.Close()
+
+	msg, err := ReadMessage(f)
+	require.NoError(t, err)
+	assert.Equal(t, "Bolt Tähtsad <noreply@bolt.eu>", msg.From)
+	assert.Equal(t, "Teie sõidu kviitung", msg.Subject)
+
+	// the html body is dropped because there are real attachments, and the text attachment is ignored
+	require.Len(t, msg.Attachments, 2)
+	assert.Equal(t, "kviitung.pdf", msg.Attachments[0].Name)
+	assert.Equal(t, PDF, msg.Attachments[0].Kind)
+	assert.Equal(t, "%PDF-1.4 fake receipt", string(msg.Attachments[0].Data))
+	assert.Equal(t, "logo.png", msg.Attachments[1].Name)
+	assert.Equal(t, PNG, msg.Attachments[1].Kind)
+}
+
+func TestReadMbox(t *testing.T) {
+	f, err := os.Open("testdata/orders.mbox")
+	require.NoError(t, err)
+	defer f.Close()
+
+	messages, err := ReadMbox(f)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+
+	wolt := messages[0]
+	assert.Equal(t, "Wolt <noreply@wolt.com>", wolt.From)
+	require.Len(t, wolt.Attachments, 1)
+	assert.Equal(t, HTML, wolt.Attachments[0].Kind)
+	assert.Equal(t, []string{"Kviitung", "Pizza 12,50", "KM 20% 2,08", "Kokku 12,50 €", "Pärnu mnt 10"}, Text(wolt.Attachments[0].Data))
+
+	selver := messages[1]
+	assert.Equal(t, "noreply@selver.ee", selver.From)
+	require.Len(t, selver.Attachments, 1)
+	assert.Equal(t, JPEG, selver.Attachments[0].Kind)
+	assert.Equal(t, "kviitung.jpg", selver.Attachments[0].Name)
+}
+
+func TestWrap(t *testing.T) {
+	assert.Equal(t, []string{"aaa bbb", "ccc"}, wrap("aaa bbb ccc", 7))
+	assert.Equal(t, []string{"aaaaaaaaa", "b"}, wrap("aaaaaaaaa b", 7))
+}
@@ -12,14 +12,19 @@ var curr2 *regexp.Regexp
 var curr3 *regexp.Regexp
 
 func init() {
-	curr2 = regexp.MustCompile(`[0-9]+(\,|\.)\s?[0-9]{2}`)
-	curr3 = regexp.MustCompile(`[0-9]+\,\s?[0-9]{3}\s?$?`)
+	// the trailing groups stop these from matching the first digits of a longer number, like the first two digits
+	// of a 3-digit currency or the year of a date
+	curr2 = regexp.MustCompile(`([0-9]+(\,|\.)\s?[0-9]{2})(?:[^0-9]|$)`)
+	curr3 = regexp.MustCompile(`([0-9]+(\,|\.)\s?[0-9]{3})(?:[^0-9]|$)`)
 }
 
 type currency struct{}
 
 func (currency) Find(r *Result, text []string) error {
-	tax, total, _ := findTaxTotal(text)
+	tax, total, precision := findLabeledTaxTotal(r.Layout)
+	if tax == 0 && total == 0 {
+		tax, total, precision = findTaxTotal(text)
+	}
 	if tax == 0 && total == 0 {
 		r.Errors = append(r.Errors, "no tax/total found")
 		return nil
@@ -27,6 +32,7 @@ func (currency) Find(r *Result, text []string) error {
 
 	r.Total = total
 	r.VAT = tax
+	r.Precision = precision
 	return nil
 }
 
@@ -34,29 +40,74 @@ func CurrencyRule() Rule {
 	return currency{}
 }
 
-// findTaxTotal returns the tax, total or 0,0 if not found
+// labels printed before the total and the VAT
+var (
+	totalLabels = []string{"kokku", "summa", "tasuda", "tasutud", "maksta", "total"}
+	vatLabels   = []string{"käibemaks", "km", "vat"}
+)
+
+// findLabeledTaxTotal reads the amounts next to or below the total and VAT labels and returns the largest total
+// that has a matching 20% VAT, or 0,0 if there isn't one
+func findLabeledTaxTotal(layout *Layout) (int, int, CurrencyPrecision) {
+	if layout == nil {
+		return 0, 0, Currency2
+	}
+	amounts := func(labels []string) []int {
+		var text []string
+		for _, label := range labels {
+			text = append(text, layout.Labeled(label)...)
+		}
+		return extractAmounts(text)
+	}
+	totals := amounts(totalLabels)
+	vats := amounts(vatLabels)
+	sort.Sort(sort.Reverse(sort.IntSlice(totals)))
+	for _, total := range totals {
+		for _, tax := range vats {
+			if isTax(tax, total) {
+				return withPrecision(tax, total)
+			}
+		}
+	}
+	return 0, 0, Currency2
+}
+
+// findTaxTotal returns the tax, total or 0,0 if not found.  Amounts are compared in thousandths so that 2-digit
+// and 3-digit currencies can be mixed on the same receipt.  The precision is 3 digits only when the tax or total
+// actually uses the third digit, otherwise both are returned in cents.
 func findTaxTotal(text []string) (int, int, CurrencyPrecision) {
-	currencies := extractCurrency3(text)
-	currencies = append(currencies, extractCurrency2(text)...)
-	precision := Currency2
+	tax, total := extractTaxTotal(extractAmounts(text))
+	return withPrecision(tax, total)
+}
 
-	tax, total := extractTaxTotal(currencies)
+// extractAmounts returns every amount in the text in thousandths
+func extractAmounts(text []string) []int {
+	currencies := extractCurrency3(text)
+	for _, c := range extractCurrency2(text) {
+		currencies = append(currencies, c*10)
+	}
+	return currencies
+}
 
-	return tax, total, precision
+// withPrecision converts the tax and total from thousandths to cents unless the third digit is used
+func withPrecision(tax int, total int) (int, int, CurrencyPrecision) {
+	if tax%10 == 0 && total%10 == 0 {
+		return tax / 10, total / 10, Currency2
+	}
+	return tax, total, Currency3
 }
 
-// extracts all numbers of the form dd+,ddd and returns them as integers in unit values (x100) to a 2-digit precision
+// extracts all numbers of the form dd+,ddd or dd+.ddd and returns them as integers in thousandths (x1000)
 func extractCurrency3(raw []string) []int {
 	out := make([]int, 0)
 	for _, line := range raw {
 		lineT := strings.Trim(line, "€*EUR eur")
-		c := curr3.FindAllString(lineT, -1)
+		c := curr3.FindAllStringSubmatch(lineT, -1)
 		for _, c1 := range c {
-			cUnit := strings.Replace(c1, ",", "", -1)
+			cUnit := strings.Replace(c1[1], ",", "", -1)
 			cUnit = strings.Replace(cUnit, ".", "", -1)
 			cUnit = strings.Replace(cUnit, " ", "", -1)
-			// lop off last digit of 3-digit currencies
-			cAsInt, err := strconv.Atoi(cUnit[0 : len(cUnit)-2])
+			cAsInt, err := strconv.Atoi(cUnit)
 			if err != nil {
 				continue
 			}
@@ -71,9 +122,9 @@ func extractCurrency2(raw []string) []int {
 	out := make([]int, 0)
 	for _, line := range raw {
 		lineT := strings.Trim(line, "€*EUR eur")
-		c := curr2.FindAllString(lineT, -1)
+		c := curr2.FindAllStringSubmatch(lineT, -1)
 		for _, c1 := range c {
-			cUnit := strings.Replace(c1, ",", "", -1)
+			cUnit := strings.Replace(c1[1], ",", "", -1)
 			cUnit = strings.Replace(cUnit, ".", "", -1)
 			cUnit = strings.Replace(cUnit, " ", "", -1)
 			cAsInt, err := strconv.Atoi(cUnit)
@@ -87,7 +138,7 @@ func extractCurrency2(raw []string) []int {
 }
 
 // determine tax and total by checking for 20% tax for every number on receipt
-// only works because the values are sorted and it starts looking at the number most likely to be total
+// only works because the values are sorted and it starts looking at the number most likely to be total.
 // TODO: doesn't handle the 9% or 10% tax brackets
 func extractTaxTotal(in []int) (tax int, total int) {
 	sort.Ints(in)
@@ -102,11 +153,10 @@ func extractTaxTotal(in []int) (tax int, total int) {
 	}
 	for _, i := range in {
 		total = i
-		expectedTax := total - int(float64(total)/1.20)
 		for _, j := range in {
-			if j >= expectedTax-1 && j <= expectedTax+1 {
+			if isTax(j, total) {
 				tax = j
-				if math.Abs(float64(maxCost-total)) <= 10 {
+				if math.Abs(float64(maxCost-total)) <= 100 {
 					total = maxCost
 				}
 				return
@@ -115,3 +165,17 @@ func extractTaxTotal(in []int) (tax int, total int) {
 	}
 	return 0, 0
 }
+
+// taxTolerance is how far the VAT can be from 20% of the total, in thousandths, which is +/- 1 cent
+const taxTolerance = 10
+
+// isTax is true when tax is the 20% VAT included in total, both in thousandths.  The expected VAT of a total in
+// cents is worked out in cents like the receipt does.
+func isTax(tax int, total int) bool {
+	expected := total - int(float64(total)/1.20)
+	if total%10 == 0 {
+		cents := total / 10
+		expected = (cents - int(float64(cents)/1.20)) * 10
+	}
+	return tax >= expected-taxTolerance && tax <= expected+taxTolerance
+}
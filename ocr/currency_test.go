@@ -8,18 +8,60 @@ import (
 
 func TestCurrency(t *testing.T) {
 	tt := []struct {
-		name  string
-		in    []string
-		tax   int
-		total int
+		name      string
+		in        []string
+		tax       int
+		total     int
+		precision CurrencyPrecision
 	}{
-		{name: "mixed like selver", in: []string{"0,988", "0,956", "68,66", "13,73", "82,39", "69,26", "13,73", "82,99"}, tax: 1373, total: 8239},
-		{name: "tax in currency3", in: []string{"4,00", "1,00", "2,00", "5,000", "1,000", "0,000", "6,00"}, tax: 100, total: 600},
-		{name: "with spaces like H&M", in: []string{"16, 67", "83, 27", "99, 94"}, tax: 1667, total: 9994},
+		{name: "mixed like selver", in: []string{"0,988", "0,956", "68,66", "13,73", "82,39", "69,26", "13,73", "82,99"}, tax: 1373, total: 8239, precision: Currency2},
+		{name: "tax in currency3", in: []string{"4,00", "1,00", "2,00", "5,000", "1,000", "0,000", "6,00"}, tax: 100, total: 600, precision: Currency2},
+		{name: "with spaces like H&M", in: []string{"16, 67", "83, 27", "99, 94"}, tax: 1667, total: 9994, precision: Currency2},
+		{name: "3-digit tax", in: []string{"116,667", "23,333", "140,00"}, tax: 23333, total: 140000, precision: Currency3},
+		{name: "3-digit with dots", in: []string{"116.667", "23.333", "140.00"}, tax: 23333, total: 140000, precision: Currency3},
+		{name: "2 cents off is not the tax", in: []string{"12,00", "2,02"}, tax: 0, total: 0, precision: Currency2},
 	}
 	for _, tc := range tt {
-		tax, total, _ := findTaxTotal(tc.in)
-		assert.Equal(t, tc.tax, tax)
-		assert.Equal(t, tc.total, total)
+		tax, total, precision := findTaxTotal(tc.in)
+		assert.Equal(t, tc.tax, tax, tc.name)
+		assert.Equal(t, tc.total, total, tc.name)
+		assert.Equal(t, tc.precision, precision, tc.name)
 	}
 }
+
+func TestCurrency3(t *testing.T) {
+	assert.Equal(t, []int{23333, 23333, 5000}, extractCurrency3([]string{"23.333", "23,333 EUR", "5,000"}))
+	assert.Empty(t, extractCurrency3([]string{"02.01.2021", "23,3333", "12,50"}))
+}
+
+func TestCurrencyLabeled(t *testing.T) {
+	// the cash paid and a gift card look like a total and its VAT to the search over all numbers
+	l := NewLayoutFromWords([]Word{
+		word("Kokku", 10, 100, 80, 130),
+		word("12,50", 600, 108, 680, 138),
+		word("KM", 10, 150, 40, 180),
+		word("20%", 50, 150, 90, 180),
+		word("2,08", 600, 158, 660, 188),
+		word("Sularaha", 10, 200, 120, 230),
+		word("60,00", 600, 200, 680, 230),
+		word("Tagasi", 10, 250, 100, 280),
+		word("47,50", 600, 250, 680, 280),
+		word("Kinkekaart", 10, 300, 140, 330),
+		word("10,00", 600, 300, 680, 330),
+	})
+	_, total, _ := findTaxTotal(l.Text())
+	assert.Equal(t, 6000, total)
+
+	tax, total, precision := findLabeledTaxTotal(l)
+	assert.Equal(t, 208, tax)
+	assert.Equal(t, 1250, total)
+	assert.Equal(t, Currency2, precision)
+
+	r := &Result{Layout: l}
+	assert.NoError(t, CurrencyRule().Find(r, l.Text()))
+	assert.Equal(t, 1250, r.Total)
+
+	tax, total, _ = findLabeledTaxTotal(nil)
+	assert.Equal(t, 0, tax)
+	assert.Equal(t, 0, total)
+}
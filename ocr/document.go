@@ -0,0 +1,135 @@
+package ocr
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// DocumentType is the kind of document in the image.  Only fiscal receipts and invoices can be claimed on the
+// VAT form.
+type DocumentType string
+
+const (
+	FiscalReceipt DocumentType = "fiscal receipt"
+	Invoice       DocumentType = "invoice"
+	CardSlip      DocumentType = "card slip"
+	OtherDocument DocumentType = "other"
+)
+
+// Claimable is true when the document can be used as a line on the VAT form
+func (d DocumentType) Claimable() bool {
+	switch d {
+	case CardSlip, OtherDocument:
+		return false
+	default:
+		return true
+	}
+}
+
+// keywords that vote for each document type.  Each keyword counts once no matter how many times it appears.
+// Keywords match whole words, a trailing * also matches longer words like the Estonian case endings.
+var documentKeywords = map[DocumentType][]*regexp.Regexp{
+	FiscalReceipt: keywords("kviitung", "tšekk", "tsekk", "kassa", "käibemaks*", "kmkr", "km", "vat", "kokku", "reg. nr", "reg nr"),
+	Invoice:       keywords("arve nr", "invoice", "maksetähtaeg", "tasumise tähtaeg", "due date", "viitenumber", "reference number", "maksja", "iban"),
+	CardSlip:      keywords("kaardimakse", "terminal", "autoriseerimis*", "authorization", "approved", "kinnitatud", "kontaktivaba", "contactless", "kliendi koopia", "customer copy", "cardholder", "kaardi omanik", "aid:", "tid:"),
+	OtherDocument: keywords("tellimuse kinnitus", "order confirmation", "tellimus nr", "boonuspunkt*", "punktisaldo", "kogutud punkt*", "väljavõte", "statement", "pakkumine"),
+}
+
+// vatKeywords show the document has a VAT line or VAT number.  Card slips never do, so the card terms that are also
+// printed on receipts paid by card can't make a receipt with these into a card slip.
+var vatKeywords = keywords("käibemaks*", "km", "kmkr", "vat")
+
+// keywords compiles keywords to patterns with word boundaries.  The boundaries are unicode aware because \b only
+// knows ASCII letters, and they are only added where the keyword starts or ends with a letter or digit so that
+// keywords like "aid:" still match "AID:A0000".
+func keywords(words ...string) []*regexp.Regexp {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+	var out []*regexp.Regexp
+	for _, w := range words {
+		prefix := strings.HasSuffix(w, "*")
+		w = strings.TrimSuffix(w, "*")
+		runes := []rune(w)
+		p := regexp.QuoteMeta(w)
+		if isWord(runes[0]) {
+			p = `(?:^|[^\pL\pN])` + p
+		}
+		if !prefix && isWord(runes[len(runes)-1]) {
+			p += `(?:[^\pL\pN]|$)`
+		}
+		out = append(out, regexp.MustCompile(p))
+	}
+	return out
+}
+
+// card slips are short, so a layout with fewer lines than this is more likely to be one
+const cardSlipMaxLines = 20
+
+type document struct{}
+
+// DocumentRule labels the image as a fiscal receipt, invoice, card slip or other document.  It should run after
+// the currency rule because a 20% VAT amount is strong evidence that the document is claimable.
+func DocumentRule() Rule {
+	return document{}
+}
+
+func (document) Find(r *Result, text []string) error {
+	numLines := 0
+	if r.Layout != nil {
+		numLines = len(r.Layout.Lines)
+	}
+	r.Document = classifyDocument(text, r.VAT > 0, numLines)
+	return nil
+}
+
+// classifyDocument scores each document type by the number of matching keywords, adding layout features when they
+// are known.  Ties are resolved in favor of claimable documents, and a document with no evidence at all is treated
+// as a fiscal receipt so that poor photos still show up on the form for review.
+func classifyDocument(text []string, hasVAT bool, numLines int) DocumentType {
+	lower := make([]string, 0, len(text))
+	for _, line := range text {
+		lower = append(lower, strings.ToLower(line))
+	}
+
+	scores := make(map[DocumentType]int)
+	for t, patterns := range documentKeywords {
+		for _, k := range patterns {
+			for _, line := range lower {
+				if k.MatchString(line) {
+					scores[t]++
+					break
+				}
+			}
+		}
+	}
+
+	if hasVAT {
+		scores[FiscalReceipt] += 2
+	}
+	if numLines > 0 && numLines < cardSlipMaxLines && scores[CardSlip] > 0 {
+		scores[CardSlip]++
+	}
+
+	candidates := []DocumentType{Invoice, OtherDocument}
+	if !hasVAT && !containsAny(lower, vatKeywords) {
+		candidates = append(candidates, CardSlip)
+	}
+	best := FiscalReceipt
+	for _, t := range candidates {
+		if scores[t] > scores[best] {
+			best = t
+		}
+	}
+	return best
+}
+
+func containsAny(lines []string, keywords []*regexp.Regexp) bool {
+	for _, k := range keywords {
+		for _, line := range lines {
+			if k.MatchString(line) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package ocr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyDocument(t *testing.T) {
+	tt := []struct {
+		name     string
+		in       []string
+		hasVAT   bool
+		numLines int
+		expect   DocumentType
+	}{
+		{name: "selver receipt", in: []string{"Selver AS", "Kviitung: 45065/90212", "KM 20% 13,73", "Kokku 82,39", "Kaardimakse"}, hasVAT: true, numLines: 40, expect: FiscalReceipt},
+		{name: "telia invoice", in: []string{"Telia Eesti AS", "Arve nr 20230220601120", "Maksetähtaeg 15.03.2023", "Viitenumber 123456"}, hasVAT: true, numLines: 60, expect: Invoice},
+		{name: "card terminal slip", in: []string{"SWEDBANK", "Terminal 12345678", "Kaardimakse", "Kontaktivaba", "AID: A0000000041010", "KINNITATUD", "Kliendi koopia"}, numLines: 14, expect: CardSlip},
+		{name: "receipt paid by card", in: []string{"Rimi Eesti Food AS", "KMKR EE100125443", "Piim 1,29", "Leib 1,89", "Terminal 12345678", "AID: A0000000041010", "Kliendi koopia", "Kaardimakse", "Kontaktivaba", "Kinnitatud"}, numLines: 30, expect: FiscalReceipt},
+		{name: "short receipt paid by card", in: []string{"Kohvik OÜ", "Kohv 3,50", "KM 20% 0,58", "Terminal 12345678", "AID: A0000000041010", "Kliendi koopia"}, numLines: 12, expect: FiscalReceipt},
+		{name: "card slip with vat inside a word", in: []string{"SWEDBANK", "Private card", "Terminal 12345678", "Kaardimakse", "AID:A0000000041010", "Kliendi koopia"}, numLines: 14, expect: CardSlip},
+		{name: "receipt with inflected vat", in: []string{"Kohvik OÜ", "Kohv 3,50", "Käibemaksuga 20% 0,58", "Terminal 12345678", "AID: A0000000041010", "Kliendi koopia"}, numLines: 12, expect: FiscalReceipt},
+		{name: "order confirmation", in: []string{"Tellimuse kinnitus", "Tellimus nr 1234", "Kokku 25,00"}, numLines: 20, expect: OtherDocument},
+		{name: "unreadable photo", in: []string{"xx", "yy"}, expect: FiscalReceipt},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got := classifyDocument(tc.in, tc.hasVAT, tc.numLines)
+			assert.Equal(t, tc.expect, got)
+			assert.Equal(t, tc.expect == FiscalReceipt || tc.expect == Invoice, got.Claimable())
+		})
+	}
+}
@@ -18,27 +18,45 @@ var telia2 *regexp.Regexp
 var alexela *regexp.Regexp
 
 func init() {
-	kviitung = regexp.MustCompile(`kviitung[^0-9]+([0-9]*\/?[0-9]*)?`)
-	arve = regexp.MustCompile(`arve[^0-9]+([0-9]*)`)
+	// matched case insensitively because receipts print the labels in capitals as often as not
+	kviitung = regexp.MustCompile(`(?i)kviitung[^0-9]+([0-9]*\/?[0-9]*)?`)
+	arve = regexp.MustCompile(`(?i)arve[^0-9]+([0-9]*)`)
 	hash = regexp.MustCompile(`#([0-9]*)`)
 	// for # that looks like h instead
-	hash2 = regexp.MustCompile(`h([0-9]*)`)
-	nr = regexp.MustCompile(`nr[^0-9]+([0-9]*)`)
-	kvarve = regexp.MustCompile(`kv-arve[^0-9]+([0-9]*)`)
-	tseki = regexp.MustCompile(`tšek[^0-9]+([0-9]*)`)
+	hash2 = regexp.MustCompile(`(?i)h([0-9]*)`)
+	nr = regexp.MustCompile(`(?i)nr[^0-9]+([0-9]*)`)
+	kvarve = regexp.MustCompile(`(?i)kv-arve[^0-9]+([0-9]*)`)
+	tseki = regexp.MustCompile(`(?i)tšek[^0-9]+([0-9]*)`)
 	// bolt uses UUIDv4, truncated to first two sections
-	boltUUID = regexp.MustCompile(`document\sno\.\s([0-9a-f]{8}-[0-9a-f]{4})`)
-	wolt = regexp.MustCompile(`order id\:?\s?([0-9a-f]+)`)
+	boltUUID = regexp.MustCompile(`(?i)document\sno\.\s([0-9a-f]{8}-[0-9a-f]{4})`)
+	wolt = regexp.MustCompile(`(?i)order id\:?\s?([0-9a-f]+)`)
 	// telia always starts with year
-	telia = regexp.MustCompile(`invoice (2023[0-9]{10}).?`)
+	telia = regexp.MustCompile(`(?i)invoice (2023[0-9]{10}).?`)
 	telia2 = regexp.MustCompile(`(2023[0-9]{10})`)
-	alexela = regexp.MustCompile(`.*arve ([0-9]+-[0-9]+)`)
+	alexela = regexp.MustCompile(`(?i).*arve ([0-9]+-[0-9]+)`)
+}
+
+// idLabels are the labels printed before the receipt number, in the order they are tried, with the pattern of the
+// number that follows
+var idLabels = []struct {
+	label string
+	value *regexp.Regexp
+}{
+	{"tsekk/arve", regexp.MustCompile(`^([0-9]+-[0-9]+)`)},
+	{"kviitung", regexp.MustCompile(`([0-9]+\/?[0-9]*)`)},
+	{"arve", regexp.MustCompile(`([0-9]+)`)},
+	{"kv-arve", regexp.MustCompile(`([0-9]+)`)},
+	{"tšek", regexp.MustCompile(`([0-9]+)`)},
+	{"document no.", regexp.MustCompile(`(?i)^([0-9a-f]{8}-[0-9a-f]{4})`)},
+	{"order id", regexp.MustCompile(`(?i)^([0-9a-f]{6,})`)},
+	{"invoice", regexp.MustCompile(`^(2023[0-9]{10})`)},
+	{"nr", regexp.MustCompile(`([0-9]+)`)},
 }
 
 type id struct{}
 
 func (id) Find(r *Result, text []string) error {
-	result := extractID(text)
+	result := extractID(r.Layout, text)
 	if result == "" {
 		r.Errors = append(r.Errors, "no receipt number found")
 		return nil
@@ -52,8 +70,19 @@ func IDRule() Rule {
 	return id{}
 }
 
-// extracts the receipt id number, looking for either kviitung or arve
-func extractID(lines []string) string {
+// extractID reads the receipt number next to or below its label.  When there is no layout or no label, it falls back
+// to searching each line for the label and number together, and for numbers that have no label.
+func extractID(layout *Layout, lines []string) string {
+	if layout != nil {
+		for _, l := range idLabels {
+			for _, text := range layout.Labeled(l.label) {
+				if k := l.value.FindStringSubmatch(text); len(k) == 2 && k[1] != "" {
+					return k[1]
+				}
+			}
+		}
+	}
+
 	regexes := []*regexp.Regexp{
 		alexela,
 		kviitung,
@@ -68,14 +97,9 @@ func extractID(lines []string) string {
 		telia2,
 		telia,
 	}
-	// this tries first with standard OCR output, then tries joining lines
-	// again to see if you get any difference
-	tries := [][]string{lines, joinFollowing(lines)}
-	for _, try := range tries {
-		for _, r := range regexes {
-			if k := idFinder(r, try); k != "" {
-				return k
-			}
+	for _, r := range regexes {
+		if k := idFinder(r, lines); k != "" {
+			return k
 		}
 	}
 	return ""
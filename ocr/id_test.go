@@ -10,11 +10,10 @@ func TestReceiptNumber(t *testing.T) {
 	tt := []struct {
 		name   string
 		input  string
-		lines  []string
 		expect string
 	}{
 		{name: "selver", input: "kviitung: 45065/90212", expect: "45065/90212"},
-		{name: "bauhaus", lines: []string{"kv-arve", "086778"}, expect: "086778"},
+		{name: "capitals", input: "KVIITUNG: 45065/90212", expect: "45065/90212"},
 		{name: "partnerkaart", input: "tšeki number: 118288", expect: "118288"},
 		{name: "bold food", input: "document no. 3188271b-eb74-44aa-8251- fa523af5242d", expect: "3188271b-eb74"},
 		{name: "wolt", input: "order id: 601c1721af7e37fd4f032954", expect: "601c1721af7e37fd4f032954"},
@@ -24,14 +23,43 @@ func TestReceiptNumber(t *testing.T) {
 	}
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
-			switch {
-			case len(tc.input) > 0:
-				out := extractID([]string{tc.input})
-				assert.Equal(t, tc.expect, out)
-			default:
-				out := extractID(tc.lines)
-				assert.Equal(t, tc.expect, out)
-			}
+			assert.Equal(t, tc.expect, extractID(nil, []string{tc.input}))
+		})
+	}
+}
+
+func TestReceiptNumberLabeled(t *testing.T) {
+	tt := []struct {
+		name   string
+		words  []Word
+		expect string
+	}{
+		{name: "bauhaus below", words: []Word{
+			word("KV-ARVE", 10, 100, 120, 130),
+			word("086778", 12, 140, 110, 170),
+		}, expect: "086778"},
+		{name: "wide column", words: []Word{
+			word("Kviitung:", 10, 100, 140, 130),
+			word("45065/90212", 600, 106, 780, 136),
+			word("#999", 10, 200, 80, 230),
+		}, expect: "45065/90212"},
+		{name: "stuck to label", words: []Word{
+			word("Kviitung:45065", 10, 100, 200, 130),
+		}, expect: "45065"},
+		{name: "two word label", words: []Word{
+			word("Order", 10, 100, 80, 130),
+			word("ID:", 90, 100, 130, 130),
+			word("601c1721af7e", 140, 100, 300, 130),
+		}, expect: "601c1721af7e"},
+		{name: "alexela", words: []Word{
+			word("Tsekk/arve", 10, 100, 150, 130),
+			word("181638-5781", 160, 100, 300, 130),
+		}, expect: "181638-5781"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			l := NewLayoutFromWords(tc.words)
+			assert.Equal(t, tc.expect, extractID(l, l.Text()))
 		})
 	}
 }
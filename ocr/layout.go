@@ -0,0 +1,396 @@
+package ocr
+
+import (
+	"sort"
+	"strings"
+
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+)
+
+// Box is an axis-aligned bounding box in image pixel coordinates
+type Box struct {
+	Left   int32
+	Top    int32
+	Right  int32
+	Bottom int32
+}
+
+func (b Box) Width() int32   { return b.Right - b.Left }
+func (b Box) Height() int32  { return b.Bottom - b.Top }
+func (b Box) CenterX() int32 { return (b.Left + b.Right) / 2 }
+func (b Box) CenterY() int32 { return (b.Top + b.Bottom) / 2 }
+
+// Union returns the smallest box containing both boxes
+func (b Box) Union(o Box) Box {
+	return Box{
+		Left:   min32(b.Left, o.Left),
+		Top:    min32(b.Top, o.Top),
+		Right:  max32(b.Right, o.Right),
+		Bottom: max32(b.Bottom, o.Bottom),
+	}
+}
+
+// overlapX returns the number of pixels that two boxes share in the X direction, which is negative
+// when there is a gap between them
+func (b Box) overlapX(o Box) int32 {
+	return min32(b.Right, o.Right) - max32(b.Left, o.Left)
+}
+
+// boxFromPoly converts a Vision bounding polygon to the tightest box around its vertices.  Rotated text
+// returns its vertices in a different order, so this doesn't assume which vertex is top-left.
+func boxFromPoly(p *pb.BoundingPoly) Box {
+	vertices := p.GetVertices()
+	if len(vertices) == 0 {
+		return Box{}
+	}
+	b := Box{Left: vertices[0].X, Top: vertices[0].Y, Right: vertices[0].X, Bottom: vertices[0].Y}
+	for _, v := range vertices[1:] {
+		b = b.Union(Box{Left: v.X, Top: v.Y, Right: v.X, Bottom: v.Y})
+	}
+	return b
+}
+
+// Word is a single recognized word and its position on the receipt
+type Word struct {
+	Text string
+	Box  Box
+}
+
+// Line is a run of words that read left to right on the same line
+type Line struct {
+	Words []Word
+	Box   Box
+}
+
+// Text returns the words in the line joined by a space
+func (l Line) Text() string {
+	words := make([]string, 0, len(l.Words))
+	for _, w := range l.Words {
+		words = append(words, w.Text)
+	}
+	return strings.Join(words, " ")
+}
+
+func (l *Line) add(w Word) {
+	if len(l.Words) == 0 {
+		l.Box = w.Box
+	} else {
+		l.Box = l.Box.Union(w.Box)
+	}
+	l.Words = append(l.Words, w)
+}
+
+// Block is a group of lines that Vision considers a single unit of text, like a paragraph or the
+// totals section of a receipt
+type Block struct {
+	Lines []Line
+	Box   Box
+}
+
+// Layout is a structured model of the text on a receipt.  Blocks preserve the reading order from Vision, while
+// Lines are reconstructed geometrically across the whole receipt so that a label on the left and a value in a
+// wide column on the right end up on the same line even when Vision puts them in different blocks.
+type Layout struct {
+	Blocks []Block
+	Lines  []Line
+	Words  []Word
+}
+
+// NewLayoutFromDocument builds a layout from the page/block/paragraph/word hierarchy returned by
+// DOCUMENT_TEXT_DETECTION.  Lines inside each block are split where Vision detected a line break.
+func NewLayoutFromDocument(doc *pb.TextAnnotation) *Layout {
+	var blocks []Block
+	var words []Word
+	for _, page := range doc.GetPages() {
+		for _, block := range page.GetBlocks() {
+			b := Block{Box: boxFromPoly(block.GetBoundingBox())}
+			var line Line
+			for _, paragraph := range block.GetParagraphs() {
+				for _, word := range paragraph.GetWords() {
+					var text strings.Builder
+					endOfLine := false
+					for _, symbol := range word.GetSymbols() {
+						text.WriteString(symbol.GetText())
+						switch symbol.GetProperty().GetDetectedBreak().GetType() {
+						case pb.TextAnnotation_DetectedBreak_EOL_SURE_SPACE, pb.TextAnnotation_DetectedBreak_LINE_BREAK:
+							endOfLine = true
+						}
+					}
+					w := Word{Text: text.String(), Box: boxFromPoly(word.GetBoundingBox())}
+					words = append(words, w)
+					line.add(w)
+					if endOfLine {
+						b.Lines = append(b.Lines, line)
+						line = Line{}
+					}
+				}
+			}
+			if len(line.Words) > 0 {
+				b.Lines = append(b.Lines, line)
+			}
+			blocks = append(blocks, b)
+		}
+	}
+	return &Layout{
+		Blocks: blocks,
+		Lines:  groupLines(words),
+		Words:  words,
+	}
+}
+
+// NewLayoutFromAnnotations builds a layout from TEXT_DETECTION output, where the first annotation is the full text
+// and the rest are individual words.  There is no block hierarchy, so the whole receipt is treated as one block
+// made of the geometric lines.
+func NewLayoutFromAnnotations(raw []*pb.EntityAnnotation) *Layout {
+	var words []Word
+	if len(raw) > 1 {
+		for _, entity := range raw[1:] {
+			if entity == nil {
+				continue
+			}
+			words = append(words, Word{Text: entity.Description, Box: boxFromPoly(entity.GetBoundingPoly())})
+		}
+	}
+	return NewLayoutFromWords(words)
+}
+
+// NewLayoutFromWords builds a layout from positioned words with no other structure
+func NewLayoutFromWords(words []Word) *Layout {
+	lines := groupLines(words)
+	l := &Layout{
+		Lines: lines,
+		Words: words,
+	}
+	if len(lines) > 0 {
+		b := Block{Lines: lines, Box: lines[0].Box}
+		for _, line := range lines[1:] {
+			b.Box = b.Box.Union(line.Box)
+		}
+		l.Blocks = []Block{b}
+	}
+	return l
+}
+
+// groupLines reconstructs lines by walking words left to right and attaching each one to the line whose
+// rightmost word is at about the same height.  Comparing against the nearest word rather than the start of
+// the line lets this follow receipts that were photographed slightly skewed.
+func groupLines(words []Word) []Line {
+	sorted := make([]Word, len(words))
+	copy(sorted, words)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Box.Left < sorted[j].Box.Left })
+
+	var lines []Line
+	for _, w := range sorted {
+		best := -1
+		bestDist := int32(0)
+		for i := range lines {
+			last := lines[i].Words[len(lines[i].Words)-1]
+			dist := abs32(last.Box.CenterY() - w.Box.CenterY())
+			if dist > sameLineTolerance(last, w) {
+				continue
+			}
+			if best < 0 || dist < bestDist {
+				best, bestDist = i, dist
+			}
+		}
+		if best < 0 {
+			lines = append(lines, Line{})
+			best = len(lines) - 1
+		}
+		lines[best].add(w)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Box.Top < lines[j].Box.Top })
+	return lines
+}
+
+// sameLineTolerance is the maximum vertical distance between the centers of two words on the same line.  It
+// scales with the text height, but never drops below lineDither for very small text.
+func sameLineTolerance(a, b Word) int32 {
+	t := min32(a.Box.Height(), b.Box.Height()) / 2
+	if t < lineDither {
+		return lineDither
+	}
+	return t
+}
+
+// Columns returns runs of words stacked vertically on top of each other, ordered top to bottom.  Receipts often
+// use these for little tables with a header and the value below it.
+func (l *Layout) Columns() [][]Word {
+	sorted := make([]Word, len(l.Words))
+	copy(sorted, l.Words)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Box.Top < sorted[j].Box.Top })
+
+	var cols [][]Word
+	for _, w := range sorted {
+		placed := false
+		for i := range cols {
+			last := cols[i][len(cols[i])-1]
+			if sameColumn(last, w) {
+				cols[i] = append(cols[i], w)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			cols = append(cols, []Word{w})
+		}
+	}
+	return cols
+}
+
+// sameColumn is true when two words overlap horizontally by at least half the width of the narrower word
+func sameColumn(a, b Word) bool {
+	narrow := min32(a.Box.Width(), b.Box.Width())
+	return a.Box.overlapX(b.Box) >= narrow/2 && a.Box.overlapX(b.Box) > 0
+}
+
+// RightOf returns the nearest word to the right of w on the same line
+func (l *Layout) RightOf(w Word) (Word, bool) {
+	var out Word
+	found := false
+	for _, other := range l.Words {
+		if other == w || other.Box.Left < w.Box.Right-lineDither {
+			continue
+		}
+		if abs32(other.Box.CenterY()-w.Box.CenterY()) > sameLineTolerance(w, other) {
+			continue
+		}
+		if !found || other.Box.Left < out.Box.Left {
+			out, found = other, true
+		}
+	}
+	return out, found
+}
+
+// Below returns the nearest word underneath w that overlaps it horizontally
+func (l *Layout) Below(w Word) (Word, bool) {
+	var out Word
+	found := false
+	for _, other := range l.Words {
+		if other == w || other.Box.Top < w.Box.Bottom-lineDither || other.Box.overlapX(w.Box) <= 0 {
+			continue
+		}
+		if !found || other.Box.Top < out.Box.Top {
+			out, found = other, true
+		}
+	}
+	return out, found
+}
+
+// Find returns all words that match, compared case insensitively
+func (l *Layout) Find(text string) []Word {
+	var out []Word
+	for _, w := range l.Words {
+		if strings.EqualFold(w.Text, text) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// Labeled returns the text next to each place the label appears: the rest of the line after the label, then the
+// line below it.  Labels match the start of a word case insensitively, and labels with several words have to match
+// successive words on the same line.  Rules use this to read a value by its label instead of searching every line.
+func (l *Layout) Labeled(label string) []string {
+	parts := strings.Fields(strings.ToLower(label))
+	if len(parts) == 0 {
+		return nil
+	}
+	var out []string
+	for _, w := range l.Words {
+		if !strings.HasPrefix(strings.ToLower(w.Text), parts[0]) {
+			continue
+		}
+		end, ok := w, true
+		for _, p := range parts[1:] {
+			next, found := l.RightOf(end)
+			if !found || !strings.HasPrefix(strings.ToLower(next.Text), p) {
+				ok = false
+				break
+			}
+			end = next
+		}
+		if !ok {
+			continue
+		}
+
+		// the value can be stuck to the label, like Kviitung:45065
+		var right []string
+		text, last := []rune(end.Text), []rune(parts[len(parts)-1])
+		if len(text) > len(last) {
+			if rest := strings.TrimLeft(string(text[len(last):]), ":.#- "); rest != "" {
+				right = append(right, rest)
+			}
+		}
+		if next, found := l.RightOf(end); found {
+			right = append(right, l.lineFrom(next))
+		}
+		if len(right) > 0 {
+			out = append(out, strings.Join(right, " "))
+		}
+		if below, found := l.Below(w); found {
+			out = append(out, l.lineFrom(below))
+		}
+	}
+	return out
+}
+
+// lineFrom returns the text of w and the words to its right on the same line
+func (l *Layout) lineFrom(w Word) string {
+	words := []string{w.Text}
+	seen := map[Word]bool{w: true}
+	for {
+		next, ok := l.RightOf(w)
+		if !ok || seen[next] {
+			break
+		}
+		seen[next] = true
+		words = append(words, next.Text)
+		w = next
+	}
+	return strings.Join(words, " ")
+}
+
+// Text returns the lines of text that the regex rules search through: the lines in Vision's reading order followed
+// by the geometric lines spanning wide columns.  Values in little tables are read with Labeled instead.
+func (l *Layout) Text() []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(line string) {
+		if !seen[line] {
+			seen[line] = true
+			out = append(out, line)
+		}
+	}
+	for _, b := range l.Blocks {
+		for _, line := range b.Lines {
+			add(line.Text())
+		}
+	}
+	for _, line := range l.Lines {
+		add(line.Text())
+	}
+	return out
+}
+
+func min32(x, y int32) int32 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+func max32(x, y int32) int32 {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+func abs32(x int32) int32 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,144 @@
+package ocr
+
+import (
+	"testing"
+
+	"github.com/BTBurke/vatinator/img"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pb "google.golang.org/genproto/googleapis/cloud/vision/v1"
+)
+
+func word(text string, left, top, right, bottom int32) Word {
+	return Word{Text: text, Box: Box{Left: left, Top: top, Right: right, Bottom: bottom}}
+}
+
+// a receipt with a wide totals column that drifts down 8 pixels across the page and a little table with the
+// receipt number below its header
+func testWords() []Word {
+	return []Word{
+		word("Kokku", 10, 100, 80, 130),
+		word("12,50", 600, 108, 680, 138),
+		word("KM", 10, 150, 40, 180),
+		word("2,08", 600, 158, 660, 188),
+		word("Arve", 300, 300, 360, 330),
+		word("1234", 305, 340, 365, 370),
+	}
+}
+
+func TestLayoutLines(t *testing.T) {
+	l := NewLayoutFromWords(testWords())
+
+	var lines []string
+	for _, line := range l.Lines {
+		lines = append(lines, line.Text())
+	}
+	assert.Equal(t, []string{"Kokku 12,50", "KM 2,08", "Arve", "1234"}, lines)
+	require.Len(t, l.Blocks, 1)
+	assert.Equal(t, Box{Left: 10, Top: 100, Right: 680, Bottom: 370}, l.Blocks[0].Box)
+}
+
+func TestLayoutNeighbours(t *testing.T) {
+	l := NewLayoutFromWords(testWords())
+
+	kokku := l.Find("kokku")
+	require.Len(t, kokku, 1)
+	right, ok := l.RightOf(kokku[0])
+	assert.True(t, ok)
+	assert.Equal(t, "12,50", right.Text)
+
+	arve := l.Find("ARVE")
+	require.Len(t, arve, 1)
+	below, ok := l.Below(arve[0])
+	assert.True(t, ok)
+	assert.Equal(t, "1234", below.Text)
+
+	_, ok = l.RightOf(right)
+	assert.False(t, ok)
+}
+
+func TestLayoutColumns(t *testing.T) {
+	l := NewLayoutFromWords(testWords())
+
+	var cols [][]string
+	for _, col := range l.Columns() {
+		var c []string
+		for _, w := range col {
+			c = append(c, w.Text)
+		}
+		cols = append(cols, c)
+	}
+	assert.Equal(t, [][]string{{"Kokku", "KM"}, {"12,50", "2,08"}, {"Arve", "1234"}}, cols)
+}
+
+func TestLayoutText(t *testing.T) {
+	l := NewLayoutFromWords(testWords())
+	assert.Equal(t, []string{"Kokku 12,50", "KM 2,08", "Arve", "1234"}, l.Text())
+	assert.Equal(t, "1234", extractID(l, l.Text()))
+}
+
+func TestLayoutLabeled(t *testing.T) {
+	l := NewLayoutFromWords(testWords())
+	assert.Equal(t, []string{"12,50", "KM 2,08"}, l.Labeled("kokku"))
+	assert.Equal(t, []string{"1234"}, l.Labeled("arve"))
+	assert.Empty(t, l.Labeled("kviitung"))
+	assert.Empty(t, l.Labeled("kokku summa"))
+}
+
+func TestLayoutFromDocument(t *testing.T) {
+	poly := func(left, top, right, bottom int32) *pb.BoundingPoly {
+		return &pb.BoundingPoly{Vertices: []*pb.Vertex{
+			{X: left, Y: top}, {X: right, Y: top}, {X: right, Y: bottom}, {X: left, Y: bottom},
+		}}
+	}
+	symbols := func(text string, brk pb.TextAnnotation_DetectedBreak_BreakType) []*pb.Symbol {
+		var out []*pb.Symbol
+		for i, r := range text {
+			s := &pb.Symbol{Text: string(r)}
+			if i == len(text)-1 {
+				s.Property = &pb.TextAnnotation_TextProperty{DetectedBreak: &pb.TextAnnotation_DetectedBreak{Type: brk}}
+			}
+			out = append(out, s)
+		}
+		return out
+	}
+	doc := &pb.TextAnnotation{Pages: []*pb.Page{{Blocks: []*pb.Block{{
+		BoundingBox: poly(10, 10, 200, 80),
+		Paragraphs: []*pb.Paragraph{{Words: []*pb.Word{
+			{Symbols: symbols("Selver", pb.TextAnnotation_DetectedBreak_SPACE), BoundingBox: poly(10, 10, 90, 40)},
+			{Symbols: symbols("AS", pb.TextAnnotation_DetectedBreak_LINE_BREAK), BoundingBox: poly(100, 10, 130, 40)},
+			{Symbols: symbols("Kviitung", pb.TextAnnotation_DetectedBreak_EOL_SURE_SPACE), BoundingBox: poly(10, 50, 120, 80)},
+		}}},
+	}}}}}
+
+	l := NewLayoutFromDocument(doc)
+	require.Len(t, l.Blocks, 1)
+	require.Len(t, l.Blocks[0].Lines, 2)
+	assert.Equal(t, "Selver AS", l.Blocks[0].Lines[0].Text())
+	assert.Equal(t, "Kviitung", l.Blocks[0].Lines[1].Text())
+	assert.Len(t, l.Words, 3)
+	assert.Equal(t, "Selver AS", extractVendor(l.Text()))
+}
+
+func TestScaleResponse(t *testing.T) {
+	poly := func(x, y int32) *pb.BoundingPoly {
+		return &pb.BoundingPoly{Vertices: []*pb.Vertex{{X: x, Y: y}}}
+	}
+	res := &pb.AnnotateImageResponse{
+		TextAnnotations: []*pb.EntityAnnotation{{BoundingPoly: poly(100, 50)}},
+		FullTextAnnotation: &pb.TextAnnotation{Pages: []*pb.Page{{
+			Width: 1024, Height: 768,
+			Blocks: []*pb.Block{{BoundingBox: poly(10, 20), Paragraphs: []*pb.Paragraph{{
+				Words: []*pb.Word{{BoundingBox: poly(30, 40), Symbols: []*pb.Symbol{{}}}},
+			}}}},
+		}}},
+	}
+
+	scaleResponse(res, img.Scale{X: 2, Y: 1.5})
+	assert.Equal(t, Box{Left: 200, Top: 75, Right: 200, Bottom: 75}, boxFromPoly(res.TextAnnotations[0].BoundingPoly))
+	page := res.FullTextAnnotation.Pages[0]
+	assert.Equal(t, int32(2048), page.Width)
+	assert.Equal(t, int32(1152), page.Height)
+	assert.Equal(t, Box{Left: 20, Top: 30, Right: 20, Bottom: 30}, boxFromPoly(page.Blocks[0].BoundingBox))
+	assert.Equal(t, Box{Left: 60, Top: 60, Right: 60, Bottom: 60}, boxFromPoly(page.Blocks[0].Paragraphs[0].Words[0].BoundingBox))
+}
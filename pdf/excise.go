@@ -7,7 +7,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -71,21 +70,30 @@ type field struct {
 	Key string `yaml:"Key"`
 }
 
+// MaxExciseLines is the number of receipt rows on one excise form
+const MaxExciseLines = 6
+
+// FillExciseOptions change how the excise form is filled
 type FillExciseOptions struct {
-	ForceRemote   bool
+	// ForceRemote fills the form with the filler service instead of in Go
+	ForceRemote bool
+	// DisableRemote is left over from when the filler service was the fallback for a missing pdftk.  The form is
+	// always filled in Go unless ForceRemote is set.
 	DisableRemote bool
 	RemoteURL     string
 	APIKey        string
+	// Template is the blank form (default: assets/excise.pdf).  The filler service always uses its own.
+	Template []byte
 }
 
+// FillExcise fills the excise form with up to MaxExciseLines receipts and saves it at path.  The form is filled in Go, so no pdftk
+// or filler service is needed.
 func FillExcise(path string, rcpts []types.Excise, md types.ExciseMetadata, opts *FillExciseOptions) error {
+	if len(rcpts) > MaxExciseLines {
+		return fmt.Errorf("excise form has %d receipts, the maximum is %d", len(rcpts), MaxExciseLines)
+	}
 	if opts == nil {
-		opts = &FillExciseOptions{
-			DisableRemote: false,
-			ForceRemote:   false,
-			RemoteURL:     DefaultURL,
-			APIKey:        DefaultAPIKey,
-		}
+		opts = &FillExciseOptions{}
 	}
 
 	// populate data for form
@@ -105,47 +113,41 @@ func FillExcise(path string, rcpts []types.Excise, md types.ExciseMetadata, opts
 	}
 	data[total] = types.Currency(tot).String()
 
-	// put input files in a temp directory
-	tmpdir, err := ioutil.TempDir("", "excise")
-	if err != nil {
-		return err
+	if opts.ForceRemote {
+		return fillRemote(data, path, opts)
 	}
-	defer os.RemoveAll(tmpdir)
-
-	fdfPath := filepath.Join(tmpdir, "data.fdf")
-	fdf, err := createFDF(data)
+	out, err := fillExcise(data, opts.Template)
 	if err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(fdfPath, fdf, 0644); err != nil {
-		return err
-	}
+	return ioutil.WriteFile(path, out, 0644)
+}
 
-	templatePath := filepath.Join(tmpdir, "template.pdf")
-	t, err := bundled.Asset("assets/excise.pdf")
+func fillExcise(data map[fieldKey]string, template []byte) ([]byte, error) {
+	names, err := loadFields()
 	if err != nil {
-		return err
-	}
-	if err := ioutil.WriteFile(templatePath, t, 0644); err != nil {
-		return err
+		return nil, err
 	}
-	// shell out for pdftk to fill form and place at path
-	if err := callPdftk(templatePath, fdfPath, path, opts); err != nil {
-		return err
+	values := make(map[string]string)
+	for key, value := range data {
+		n, ok := names[key]
+		if !ok {
+			return nil, fmt.Errorf("no excise form field for %s", key)
+		}
+		values[n] = value
 	}
 
-	return nil
-
-}
-
-var DefaultAPIKey string = apiKey(".cfg/key.json")
-
-func apiKey(path string) string {
-	data, err := ioutil.ReadFile(path)
+	if len(template) == 0 {
+		template, err = bundled.Asset("assets/excise.pdf")
+		if err != nil {
+			return nil, err
+		}
+	}
+	out, err := fillForm(template, values)
 	if err != nil {
-		return ""
+		return nil, errors.Wrap(err, "failed to fill excise form")
 	}
-	return fmt.Sprintf("%x", sha256.Sum256(data))
+	return out, nil
 }
 
 func loadFields() (map[fieldKey]string, error) {
@@ -175,6 +177,46 @@ func loadFields() (map[fieldKey]string, error) {
 	return fieldMap, nil
 }
 
+var DefaultAPIKey string = apiKey(".cfg/key.json")
+
+func apiKey(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// fillRemote sends the form data to the filler service, which fills the form with pdftk
+func fillRemote(data map[fieldKey]string, path string, opts *FillExciseOptions) error {
+	url, key := opts.RemoteURL, opts.APIKey
+	if url == "" {
+		url = DefaultURL
+	}
+	if key == "" {
+		key = DefaultAPIKey
+	}
+	if key == "" {
+		return errors.New("no API key to call the filler service")
+	}
+
+	tmpdir, err := ioutil.TempDir("", "excise")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	fdfPath := filepath.Join(tmpdir, "data.fdf")
+	fdf, err := createFDF(data)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(fdfPath, fdf, 0644); err != nil {
+		return err
+	}
+	return callPdftkRemote(url, fdfPath, path, key)
+}
+
 func createFDF(data map[fieldKey]string) ([]byte, error) {
 	names, err := loadFields()
 	if err != nil {
@@ -206,25 +248,6 @@ func createFDF(data map[fieldKey]string) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-func callPdftk(template string, fdf string, out string, opts *FillExciseOptions) error {
-	bin, err := exec.LookPath("pdftk")
-	if err != nil || opts.ForceRemote {
-		if !opts.DisableRemote && len(opts.APIKey) > 0 && len(opts.RemoteURL) > 0 {
-			// no local pdftk so use remote service
-			return callPdftkRemote(opts.RemoteURL, fdf, out, opts.APIKey)
-		} else {
-			return errors.Wrap(err, "no local pdftk and either remote disabled or not API information to call remote service")
-		}
-	}
-	cmd := exec.Command(bin, template, "fill_form", fdf, "output", out)
-	stdouterr, err := cmd.CombinedOutput()
-	if err != nil {
-		return errors.Wrap(err, string(stdouterr))
-	}
-
-	return nil
-}
-
 func callPdftkRemote(url string, fdf string, out string, apikey string) error {
 	if url == "" {
 		url = "localhost:8080"
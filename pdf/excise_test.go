@@ -1,12 +1,14 @@
 package pdf
 
 import (
+	"bytes"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
 
-	"github.com/BTBurke/snapshot"
 	"github.com/BTBurke/vatinator/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,7 +27,7 @@ func TestFillExciseForm(t *testing.T) {
 	testMD := types.ExciseMetadata{
 		Embassy: "US Embassy",
 		Name:    "Bryan Burke",
-		Bank:    "My Bank, Liiviala 9, Tallinn 001110, EE220000000000",
+		Bank:    "Swedbank, Liivalaia 8, Tallinn 15040, EE220000000000 õäöüšž",
 		Date:    "December 2020",
 	}
 	testReceipts := []types.Excise{testExcise, testExcise, testExcise, testExcise, testExcise, testExcise}
@@ -35,54 +37,99 @@ func TestFillExciseForm(t *testing.T) {
 	defer os.RemoveAll(tmpdir)
 
 	outPath := filepath.Join(tmpdir, "out.pdf")
-	if err := FillExcise(outPath, testReceipts, testMD, &FillExciseOptions{
-		DisableRemote: true,
-	}); err != nil {
-		assert.NoError(t, err)
-	}
+	require.NoError(t, FillExcise(outPath, testReceipts, testMD, nil))
 	out, err := ioutil.ReadFile(outPath)
 	require.NoError(t, err)
-	snap, err := snapshot.New(snapshot.SnapExtension(".pdf"), snapshot.Diffable(false))
+
+	// the template is unchanged with the filled fields appended
+	template, err := ioutil.ReadFile("../assets/excise.pdf")
 	require.NoError(t, err)
-	snap.Assert(t, out)
-}
+	assert.True(t, bytes.HasPrefix(out, template))
 
-func TestRemoteFill(t *testing.T) {
-	testExcise := types.Excise{
-		Type:    "Gas 95",
-		Arve:    "11111",
-		Content: "test",
-		Tax:     1000,
-		Amount:  "25L",
-		Date:    "24/12/2020",
+	d, err := readDocument(out)
+	require.NoError(t, err)
+	fields, err := d.fields(d.dict(d.dict(d.trailer["Root"])["AcroForm"]))
+	require.NoError(t, err)
+	names, err := loadFields()
+	require.NoError(t, err)
+
+	values := make(map[string]string)
+	for _, f := range fields {
+		v, ok := f.dict["V"].(pdfString)
+		if !ok {
+			continue
+		}
+		values[f.name] = decodeTextString(v)
+
+		// every filled field draws its value
+		require.Len(t, f.widgets, 1)
+		ap := d.dict(f.widgets[0].dict["AP"])
+		require.NotNil(t, ap, f.name)
+		o, err := d.resolve(ap["N"])
+		require.NoError(t, err)
+		s, ok := o.(pdfStream)
+		require.True(t, ok)
+		content, err := d.decode(s)
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "/Helv")
 	}
 
-	testMD := types.ExciseMetadata{
-		Embassy: "US Embassy",
-		Name:    "Bryan Burke",
-		Bank:    "My Bank, Liiviala 9, Tallinn 001110, EE220000000000",
-		Date:    "December 2020",
+	tt := []struct {
+		key  fieldKey
+		want string
+	}{
+		{embassy, "US Embassy"},
+		{name, "Bryan Burke"},
+		{bank, testMD.Bank},
+		{date, "December 2020"},
+		{type1, "Gas 95"},
+		{amount6, "25L"},
+		{excise3, "10.00"},
+		{arve2, "11111 / 24/12/2020"},
+		{total, "60.00"},
 	}
-	testReceipts := []types.Excise{testExcise, testExcise, testExcise, testExcise, testExcise, testExcise}
+	for _, tc := range tt {
+		t.Run(string(tc.key), func(t *testing.T) {
+			assert.Equal(t, tc.want, values[names[tc.key]])
+		})
+	}
+}
 
-	tmpdir, err := ioutil.TempDir("", "excise-test")
-	require.NoError(t, err)
-	defer os.RemoveAll(tmpdir)
+func TestFillExciseUnknownField(t *testing.T) {
+	_, err := fillExcise(map[fieldKey]string{"nope": "x"}, nil)
+	assert.Error(t, err)
+}
 
-	outPath := filepath.Join(tmpdir, "out.pdf")
-	key := apiKey("../vatinator-f91ccb107c2c.json")
-	require.Greater(t, len(key), 0)
+func TestFillExciseTooManyReceipts(t *testing.T) {
+	receipts := make([]types.Excise, MaxExciseLines+1)
+	err := FillExcise(filepath.Join(os.TempDir(), "never-written.pdf"), receipts, types.ExciseMetadata{}, nil)
+	assert.Error(t, err)
+}
+
+func TestFillExciseRemote(t *testing.T) {
+	var got []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		got, _ = ioutil.ReadAll(r.Body)
+		w.Write([]byte("%PDF-filled"))
+	}))
+	defer srv.Close()
 
-	if err := FillExcise(outPath, testReceipts, testMD, &FillExciseOptions{
+	outPath := filepath.Join(t.TempDir(), "out.pdf")
+	receipts := []types.Excise{{Type: "Gas 95", Arve: "11111", Tax: 1000, Amount: "25L", Date: "24/12/2020"}}
+	require.NoError(t, FillExcise(outPath, receipts, types.ExciseMetadata{Name: "Bryan Burke"}, &FillExciseOptions{
 		ForceRemote: true,
-		RemoteURL:   DefaultURL,
-		APIKey:      apiKey("../vatinator-f91ccb107c2c.json"),
-	}); err != nil {
-		require.NoError(t, err)
-	}
+		RemoteURL:   srv.URL,
+		APIKey:      "key",
+	}))
 	out, err := ioutil.ReadFile(outPath)
 	require.NoError(t, err)
-	snap, err := snapshot.New(snapshot.SnapExtension(".pdf"), snapshot.Diffable(false))
-	require.NoError(t, err)
-	snap.Assert(t, out)
+	assert.Equal(t, "%PDF-filled", string(out))
+	assert.True(t, bytes.HasPrefix(got, []byte("%FDF-1.2")))
+
+	err = FillExcise(outPath, receipts, types.ExciseMetadata{}, &FillExciseOptions{ForceRemote: true, RemoteURL: srv.URL, APIKey: "wrong"})
+	assert.Error(t, err)
 }
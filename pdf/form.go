@@ -0,0 +1,515 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// text field flag for fields that wrap onto several lines
+const fieldMultiline = 1 << 12
+
+// formField is a terminal field of the AcroForm with its widgets, which are the boxes drawn on the page.  Most
+// templates merge the field and its only widget into one dictionary.
+type formField struct {
+	ref     pdfRef
+	dict    pdfDict
+	name    string
+	widgets []formWidget
+	// inherited holds the values of DA, Q, Ff, FT and DR from the field or its nearest parent
+	inherited pdfDict
+}
+
+type formWidget struct {
+	ref  pdfRef
+	dict pdfDict
+}
+
+// fillForm sets the text fields named in values and adds an appearance for each so the form looks filled in
+// without the viewer regenerating it.  The changes are appended to template as an incremental update.
+func fillForm(template []byte, values map[string]string) ([]byte, error) {
+	d, err := readDocument(template)
+	if err != nil {
+		return nil, err
+	}
+	root := d.dict(d.trailer["Root"])
+	acroform := d.dict(root["AcroForm"])
+	if acroform == nil {
+		return nil, fmt.Errorf("PDF has no form")
+	}
+	fields, err := d.fields(acroform)
+	if err != nil {
+		return nil, err
+	}
+
+	u := newUpdate(d)
+	found := make(map[string]bool)
+	for _, f := range fields {
+		value, ok := values[f.name]
+		if !ok {
+			continue
+		}
+		found[f.name] = true
+		if f.inherited["FT"] != pdfName("Tx") {
+			return nil, fmt.Errorf("form field %q is not a text field", f.name)
+		}
+		v, err := encodeTextString(value)
+		if err != nil {
+			return nil, err
+		}
+		f.dict["V"] = v
+
+		for _, w := range f.widgets {
+			ap, err := d.appearance(f, w.dict, acroform, value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create appearance for form field %q: %v", f.name, err)
+			}
+			w.dict["AP"] = pdfDict{"N": u.add(ap)}
+			if w.ref != f.ref {
+				u.set(w.ref, w.dict)
+			}
+		}
+		u.set(f.ref, f.dict)
+	}
+
+	var missing []string
+	for name := range values {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("no form field named %q", strings.Join(missing, `", "`))
+	}
+	return u.bytes(), nil
+}
+
+// fields walks the field tree and returns the terminal fields with their full names, which are the partial names
+// of the field and its parents joined with periods
+func (d *document) fields(acroform pdfDict) ([]formField, error) {
+	var out []formField
+	inherit := pdfDict{}
+	for _, k := range []pdfName{"DA", "Q", "DR"} {
+		if v, ok := acroform[k]; ok {
+			inherit[k] = v
+		}
+	}
+
+	var walk func(kids pdfArray, parent string, inherited pdfDict, depth int) error
+	walk = func(kids pdfArray, parent string, inherited pdfDict, depth int) error {
+		if depth > 32 {
+			return fmt.Errorf("form fields are nested too deep")
+		}
+		for _, k := range kids {
+			ref, ok := k.(pdfRef)
+			if !ok {
+				continue
+			}
+			dict := d.dict(ref)
+			if dict == nil {
+				continue
+			}
+			name := parent
+			if t, ok := dict["T"].(pdfString); ok {
+				if name != "" {
+					name += "."
+				}
+				name += decodeTextString(t)
+			}
+			inh := pdfDict{}
+			for k, v := range inherited {
+				inh[k] = v
+			}
+			for _, k := range []pdfName{"DA", "Q", "Ff", "FT", "DR"} {
+				if v, ok := dict[k]; ok {
+					inh[k] = v
+				}
+			}
+
+			// kids with names are fields, kids without are the widgets of this field
+			children, _ := dict["Kids"].(pdfArray)
+			var widgets []formWidget
+			var subfields pdfArray
+			for _, c := range children {
+				cref, ok := c.(pdfRef)
+				if !ok {
+					continue
+				}
+				cd := d.dict(cref)
+				if cd == nil {
+					continue
+				}
+				if _, named := cd["T"]; named {
+					subfields = append(subfields, cref)
+				} else {
+					widgets = append(widgets, formWidget{cref, cd})
+				}
+			}
+			if len(subfields) > 0 {
+				if err := walk(subfields, name, inh, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			if len(children) == 0 {
+				widgets = []formWidget{{ref, dict}}
+			}
+			out = append(out, formField{ref: ref, dict: dict, name: name, widgets: widgets, inherited: inh})
+		}
+		return nil
+	}
+	top, _ := acroform["Fields"].(pdfArray)
+	if r, ok := acroform["Fields"].(pdfRef); ok {
+		o, err := d.resolve(r)
+		if err != nil {
+			return nil, err
+		}
+		top, _ = o.(pdfArray)
+	}
+	if err := walk(top, "", inherit, 0); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// appearance draws value in a form XObject the size of the widget using the field's default appearance.  Text is
+// shrunk until it fits and multiline fields wrap on spaces.
+func (d *document) appearance(f formField, widget pdfDict, acroform pdfDict, value string) (pdfStream, error) {
+	rect, err := d.rect(widget["Rect"])
+	if err != nil {
+		return pdfStream{}, err
+	}
+	w, h := rect[2]-rect[0], rect[3]-rect[1]
+
+	da := f.inherited["DA"]
+	if v, ok := widget["DA"]; ok {
+		da = v
+	}
+	daStr, _ := da.(pdfString)
+	font, size, color := parseDA(string(daStr))
+	if font == "" {
+		return pdfStream{}, fmt.Errorf("no font in default appearance %q", daStr)
+	}
+	fontRef, err := d.font(font, widget, f.inherited, acroform)
+	if err != nil {
+		return pdfStream{}, err
+	}
+
+	quadding, _ := f.inherited["Q"].(int)
+	flags, _ := f.inherited["Ff"].(int)
+	lines := layoutText(value, w-4, h-2, size, flags&fieldMultiline != 0)
+
+	c := new(bytes.Buffer)
+	fmt.Fprintf(c, "/Tx BMC\nq\n1 1 %s %s re W n\nBT\n/%s %s Tf\n", num(w-2), num(h-2), font, num(lines.size))
+	if color != "" {
+		c.WriteString(color + "\n")
+	}
+	for i, line := range lines.text {
+		x := 2.0
+		switch quadding {
+		case 1:
+			x = (w - lines.widths[i]) / 2
+		case 2:
+			x = w - 2 - lines.widths[i]
+		}
+		// single lines are centered vertically, multiple lines start from the top
+		y := (h-lines.size)/2 + 0.22*lines.size
+		if len(lines.text) > 1 || flags&fieldMultiline != 0 {
+			y = h - 2 - lines.size*0.88 - float64(i)*lines.size*lineSpacing
+		}
+		fmt.Fprintf(c, "1 0 0 1 %s %s Tm\n", num(x), num(y))
+		writeObject(c, pdfString(pdfDocEncode(line)))
+		c.WriteString(" Tj\n")
+	}
+	c.WriteString("ET\nQ\nEMC")
+
+	return pdfStream{
+		dict: pdfDict{
+			"Type":      pdfName("XObject"),
+			"Subtype":   pdfName("Form"),
+			"BBox":      pdfArray{0, 0, round(w), round(h)},
+			"Resources": pdfDict{"Font": pdfDict{pdfName(font): fontRef}},
+		},
+		data: c.Bytes(),
+	}, nil
+}
+
+func (d *document) rect(o interface{}) ([4]float64, error) {
+	var r [4]float64
+	o, err := d.resolve(o)
+	if err != nil {
+		return r, err
+	}
+	a, ok := o.(pdfArray)
+	if !ok || len(a) != 4 {
+		return r, fmt.Errorf("widget has no Rect")
+	}
+	for i, v := range a {
+		switch n := v.(type) {
+		case int:
+			r[i] = float64(n)
+		case float64:
+			r[i] = n
+		}
+	}
+	if r[0] > r[2] {
+		r[0], r[2] = r[2], r[0]
+	}
+	if r[1] > r[3] {
+		r[1], r[3] = r[3], r[1]
+	}
+	return r, nil
+}
+
+// font finds the font named in the default appearance in the first default resources that have it
+func (d *document) font(font string, widget pdfDict, inherited pdfDict, acroform pdfDict) (interface{}, error) {
+	for _, dr := range []interface{}{widget["DR"], inherited["DR"], acroform["DR"]} {
+		fonts := d.dict(d.dict(dr)["Font"])
+		if f, ok := fonts[pdfName(font)]; ok {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("font %s is not in the form resources", font)
+}
+
+// parseDA splits a default appearance like "/Helv 9 Tf 0 g" into the font, the size and the color operators
+func parseDA(da string) (font string, size float64, color string) {
+	fields := strings.Fields(da)
+	var rest []string
+	for i := 0; i < len(fields); i++ {
+		if i+2 < len(fields) && fields[i+2] == "Tf" && strings.HasPrefix(fields[i], "/") {
+			font = strings.TrimPrefix(fields[i], "/")
+			size, _ = strconv.ParseFloat(fields[i+1], 64)
+			i += 2
+			continue
+		}
+		rest = append(rest, fields[i])
+	}
+	return font, size, strings.Join(rest, " ")
+}
+
+// lineSpacing is the distance between the baselines of wrapped lines as a multiple of the font size
+const lineSpacing = 1.15
+
+type textLayout struct {
+	text   []string
+	widths []float64
+	size   float64
+}
+
+// layoutText picks the font size and breaks value into lines that fit in w x h points.  A size of 0 means auto,
+// which starts at 12 points.  Widths are measured with Helvetica, the font form templates almost always use.
+func layoutText(value string, w, h float64, size float64, multiline bool) textLayout {
+	m := gofpdf.New("P", "pt", "A4", "")
+	tr := m.UnicodeTranslatorFromDescriptor("")
+	m.SetFont("Helvetica", "", 1)
+	width := func(s string, size float64) float64 { return m.GetStringWidth(tr(s)) * size }
+
+	if size <= 0 {
+		size = math.Min(12, h/lineSpacing)
+	}
+	for ; ; size -= 0.5 {
+		var lines []string
+		if multiline {
+			lines = wrap(value, func(s string) bool { return width(s, size) <= w })
+		} else {
+			lines = []string{value}
+		}
+		fits := float64(len(lines))*size*lineSpacing <= h+size*(lineSpacing-1)
+		for _, l := range lines {
+			fits = fits && width(l, size) <= w
+		}
+		if fits || size <= 4 {
+			out := textLayout{text: lines, size: size}
+			for _, l := range lines {
+				out.widths = append(out.widths, width(l, size))
+			}
+			return out
+		}
+	}
+}
+
+// wrap breaks s on spaces into lines that fit.  Words that are too long on their own get a line to themselves.
+func wrap(s string, fits func(string) bool) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(para) {
+			switch {
+			case line == "":
+				line = word
+			case fits(line + " " + word):
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(round(f), 'f', -1, 64)
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// encodeTextString encodes s as UTF-16 with a byte order mark, which is how PDF text strings hold characters
+// outside of PDFDocEncoding
+func encodeTextString(s string) (pdfString, error) {
+	b, err := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		return nil, err
+	}
+	return pdfString(b), nil
+}
+
+// decodeTextString reads a PDF text string, which is UTF-16 if it starts with a byte order mark and
+// PDFDocEncoding otherwise
+func decodeTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+		for k, v := range pdfDocSpecial {
+			if v == c {
+				r[i] = k
+			}
+		}
+	}
+	return string(r)
+}
+
+// pdfDocEncode converts s to PDFDocEncoding, which is Latin-1 with extra characters in 0x80 to 0xA0.  That's the
+// encoding of the font in the form resources and covers Estonian.  Anything else becomes a question mark.
+func pdfDocEncode(s string) []byte {
+	var b []byte
+	for _, r := range s {
+		switch c, ok := pdfDocSpecial[r]; {
+		case ok:
+			b = append(b, c)
+		case r == 0xa0:
+			b = append(b, ' ')
+		case r >= 0x20 && r < 0x7f, r > 0xa0 && r <= 0xff:
+			b = append(b, byte(r))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+var pdfDocSpecial = map[rune]byte{
+	'•': 0x80, '†': 0x81, '‡': 0x82, '…': 0x83, '—': 0x84, '–': 0x85, 'ƒ': 0x86, '⁄': 0x87,
+	'‹': 0x88, '›': 0x89, '−': 0x8a, '‰': 0x8b, '„': 0x8c, '“': 0x8d, '”': 0x8e, '‘': 0x8f,
+	'’': 0x90, '‚': 0x91, '™': 0x92, 'ﬁ': 0x93, 'ﬂ': 0x94, 'Ł': 0x95, 'Œ': 0x96, 'Š': 0x97,
+	'Ÿ': 0x98, 'Ž': 0x99, 'ı': 0x9a, 'ł': 0x9b, 'œ': 0x9c, 'š': 0x9d, 'ž': 0x9e, '€': 0xa0,
+}
+
+// update collects changed and new objects and appends them to the original file
+type update struct {
+	d       *document
+	objects map[int]interface{}
+	gens    map[int]int
+	next    int
+}
+
+func newUpdate(d *document) *update {
+	size, _ := d.trailer["Size"].(int)
+	for n := range d.xref {
+		if n >= size {
+			size = n + 1
+		}
+	}
+	return &update{
+		d:       d,
+		objects: make(map[int]interface{}),
+		gens:    make(map[int]int),
+		next:    size,
+	}
+}
+
+// add adds a new object and returns a reference to it
+func (u *update) add(o interface{}) pdfRef {
+	ref := pdfRef{u.next, 0}
+	u.next++
+	u.objects[ref.num] = o
+	return ref
+}
+
+// set replaces an existing object
+func (u *update) set(ref pdfRef, o interface{}) {
+	u.objects[ref.num] = o
+	u.gens[ref.num] = ref.gen
+}
+
+// bytes returns the original file followed by the changed objects and a cross reference stream for them
+func (u *update) bytes() []byte {
+	b := bytes.NewBuffer(append([]byte(nil), u.d.data...))
+	if last := u.d.data[len(u.d.data)-1]; last != '\n' && last != '\r' {
+		b.WriteByte('\n')
+	}
+
+	nums := make([]int, 0, len(u.objects)+1)
+	for n := range u.objects {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	offsets := make(map[int]int)
+	for _, n := range nums {
+		offsets[n] = b.Len()
+		fmt.Fprintf(b, "%d %d obj\n", n, u.gens[n])
+		writeObject(b, u.objects[n])
+		b.WriteString("\nendobj\n")
+	}
+
+	// the cross reference stream lists itself
+	xrefNum := u.next
+	nums = append(nums, xrefNum)
+	offsets[xrefNum] = b.Len()
+	var index pdfArray
+	var rows []byte
+	for i, n := range nums {
+		if i == 0 || n != nums[i-1]+1 {
+			index = append(index, n, 0)
+		}
+		index[len(index)-1] = index[len(index)-1].(int) + 1
+		off := offsets[n]
+		rows = append(rows, 1, byte(off>>24), byte(off>>16), byte(off>>8), byte(off), byte(u.gens[n]>>8), byte(u.gens[n]))
+	}
+	dict := pdfDict{
+		"Type":  pdfName("XRef"),
+		"Size":  xrefNum + 1,
+		"Index": index,
+		"W":     pdfArray{1, 4, 2},
+		"Prev":  u.d.startxref,
+		"Root":  u.d.trailer["Root"],
+	}
+	for _, k := range []pdfName{"Info", "ID"} {
+		if v, ok := u.d.trailer[k]; ok {
+			dict[k] = v
+		}
+	}
+	fmt.Fprintf(b, "%d 0 obj\n", xrefNum)
+	writeObject(b, pdfStream{dict: dict, data: rows})
+	fmt.Fprintf(b, "\nendobj\nstartxref\n%d\n%%%%EOF\n", offsets[xrefNum])
+	return b.Bytes()
+}
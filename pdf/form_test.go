@@ -0,0 +1,77 @@
+package pdf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectRoundTrip(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+		want interface{}
+	}{
+		{"int", "42", 42},
+		{"real", "-1.5", -1.5},
+		{"ref", "12 0 R", pdfRef{12, 0}},
+		{"name", "/A#20B", pdfName("A B")},
+		{"literal", `(a\(b\)\\c\101)`, pdfString(`a(b)\cA`)},
+		{"hex", "<FEFF00E4>", pdfString("\xfe\xff\x00\xe4")},
+		{"array", "[1 2 0 R /N null true]", pdfArray{1, pdfRef{2, 0}, pdfName("N"), nil, true}},
+		{"dict", "<</Kids[3 0 R]/T(x)/Q 1>>", pdfDict{"Kids": pdfArray{pdfRef{3, 0}}, "T": pdfString("x"), "Q": 1}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &parser{b: []byte(tc.in)}
+			o, err := p.object()
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, o)
+
+			// what's written reads back the same
+			b := new(bytes.Buffer)
+			writeObject(b, o)
+			p = &parser{b: b.Bytes()}
+			again, err := p.object()
+			require.NoError(t, err)
+			assert.Equal(t, o, again)
+		})
+	}
+}
+
+func TestTextString(t *testing.T) {
+	s, err := encodeTextString("Välisesinduse nimi")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xfe, 0xff}, []byte(s[:2]))
+	assert.Equal(t, "Välisesinduse nimi", decodeTextString(s))
+	assert.Equal(t, "Välisesinduse nimi", decodeTextString([]byte("V\xe4lisesinduse nimi")))
+	assert.Equal(t, []byte("\x9d\xf5 ?"), pdfDocEncode("šõ 漢"))
+}
+
+func TestLayoutText(t *testing.T) {
+	tt := []struct {
+		name      string
+		value     string
+		multiline bool
+		lines     int
+		size      float64
+	}{
+		{"fits", "Gas 95", false, 1, 9},
+		{"shrinks", "a very long value that will never fit in a box this small", false, 1, 4},
+		{"wraps", "11111 / 24/12/2020", true, 2, 9},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			l := layoutText(tc.value, 70, 24, 9, tc.multiline)
+			assert.Len(t, l.text, tc.lines)
+			assert.Equal(t, tc.size, l.size)
+			for _, w := range l.widths {
+				if l.size > 4 {
+					assert.LessOrEqual(t, w, 70.0)
+				}
+			}
+		})
+	}
+}
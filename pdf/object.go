@@ -0,0 +1,362 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// Just enough of the PDF object syntax to read a form template and write an incremental update to it.  Integers
+// are parsed as int and reals as float64.
+
+type pdfName string
+
+type pdfString []byte
+
+type pdfArray []interface{}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfRef struct {
+	num, gen int
+}
+
+// pdfStream holds the stream data as it is in the file, still encoded with its filters
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+type parser struct {
+	b   []byte
+	pos int
+}
+
+func isWhite(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.b) {
+		c := p.b[p.pos]
+		switch {
+		case isWhite(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.b) && p.b[p.pos] != '\n' && p.b[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// token reads a run of regular characters, like a keyword or a number
+func (p *parser) token() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.b) && !isWhite(p.b[p.pos]) && !isDelim(p.b[p.pos]) {
+		p.pos++
+	}
+	return string(p.b[start:p.pos])
+}
+
+func (p *parser) int() (int, error) {
+	tok := p.token()
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		return 0, fmt.Errorf("expected an integer at offset %d, got %q", p.pos, tok)
+	}
+	return n, nil
+}
+
+func (p *parser) object() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c := p.b[p.pos]; {
+	case c == '/':
+		return p.name(), nil
+	case c == '(':
+		return p.literal()
+	case c == '<':
+		if p.pos+1 < len(p.b) && p.b[p.pos+1] == '<' {
+			p.pos += 2
+			return p.dict()
+		}
+		return p.hex()
+	case c == '[':
+		p.pos++
+		return p.array()
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	}
+	start := p.pos
+	switch tok := p.token(); tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok, start)
+	}
+}
+
+func (p *parser) name() pdfName {
+	p.pos++
+	var b []byte
+	for p.pos < len(p.b) && !isWhite(p.b[p.pos]) && !isDelim(p.b[p.pos]) {
+		c := p.b[p.pos]
+		if c == '#' && p.pos+2 < len(p.b) {
+			if v, err := strconv.ParseUint(string(p.b[p.pos+1:p.pos+3]), 16, 8); err == nil {
+				b = append(b, byte(v))
+				p.pos += 3
+				continue
+			}
+		}
+		b = append(b, c)
+		p.pos++
+	}
+	return pdfName(b)
+}
+
+// number reads an integer, a real or an indirect reference like 12 0 R
+func (p *parser) number() (interface{}, error) {
+	start := p.pos
+	tok := p.token()
+	n, err := strconv.Atoi(tok)
+	if err != nil {
+		f, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q at offset %d", tok, start)
+		}
+		return f, nil
+	}
+
+	// look ahead for a generation number and R
+	end := p.pos
+	if gen, err := p.int(); err == nil && p.token() == "R" {
+		return pdfRef{n, gen}, nil
+	}
+	p.pos = end
+	return n, nil
+}
+
+func (p *parser) literal() (pdfString, error) {
+	p.pos++
+	var b []byte
+	depth := 1
+	for p.pos < len(p.b) {
+		c := p.b[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return b, nil
+			}
+		case '\r':
+			// end of line inside a string is always read as \n
+			if p.pos < len(p.b) && p.b[p.pos] == '\n' {
+				p.pos++
+			}
+			c = '\n'
+		case '\\':
+			if p.pos >= len(p.b) {
+				break
+			}
+			e := p.b[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.pos < len(p.b) && p.b[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := int(e - '0')
+				for i := 0; i < 2 && p.pos < len(p.b) && p.b[p.pos] >= '0' && p.b[p.pos] <= '7'; i++ {
+					v = v*8 + int(p.b[p.pos]-'0')
+					p.pos++
+				}
+				c = byte(v)
+			default:
+				c = e
+			}
+		}
+		b = append(b, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (p *parser) hex() (pdfString, error) {
+	p.pos++
+	var digits []byte
+	for p.pos < len(p.b) && p.b[p.pos] != '>' {
+		if !isWhite(p.b[p.pos]) {
+			digits = append(digits, p.b[p.pos])
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.b) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	p.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("bad hex string at offset %d", p.pos)
+		}
+		b[i] = byte(v)
+	}
+	return b, nil
+}
+
+func (p *parser) array() (pdfArray, error) {
+	a := pdfArray{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if p.b[p.pos] == ']' {
+			p.pos++
+			return a, nil
+		}
+		o, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, o)
+	}
+}
+
+func (p *parser) dict() (pdfDict, error) {
+	d := pdfDict{}
+	for {
+		p.skipSpace()
+		if p.pos+1 >= len(p.b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if p.b[p.pos] == '>' && p.b[p.pos+1] == '>' {
+			p.pos += 2
+			return d, nil
+		}
+		if p.b[p.pos] != '/' {
+			return nil, fmt.Errorf("expected a name in dictionary at offset %d", p.pos)
+		}
+		key := p.name()
+		v, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		d[key] = v
+	}
+}
+
+// writeObject writes o in PDF syntax.  Dictionary keys are sorted so the output is the same every time.
+func writeObject(w *bytes.Buffer, o interface{}) {
+	switch v := o.(type) {
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(v))
+	case int:
+		w.WriteString(strconv.Itoa(v))
+	case float64:
+		w.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	case pdfName:
+		w.WriteByte('/')
+		for _, c := range []byte(v) {
+			if c < '!' || c > '~' || c == '#' || isDelim(c) {
+				fmt.Fprintf(w, "#%02X", c)
+			} else {
+				w.WriteByte(c)
+			}
+		}
+	case pdfString:
+		w.WriteByte('(')
+		for _, c := range v {
+			switch c {
+			case '(', ')', '\\':
+				w.WriteByte('\\')
+				w.WriteByte(c)
+			case '\r':
+				w.WriteString(`\r`)
+			case '\n':
+				w.WriteString(`\n`)
+			default:
+				w.WriteByte(c)
+			}
+		}
+		w.WriteByte(')')
+	case pdfArray:
+		w.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				w.WriteByte(' ')
+			}
+			writeObject(w, item)
+		}
+		w.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		w.WriteString("<<")
+		for _, k := range keys {
+			writeObject(w, pdfName(k))
+			w.WriteByte(' ')
+			writeObject(w, v[pdfName(k)])
+		}
+		w.WriteString(">>")
+	case pdfRef:
+		fmt.Fprintf(w, "%d %d R", v.num, v.gen)
+	case pdfStream:
+		d := pdfDict{}
+		for k, val := range v.dict {
+			d[k] = val
+		}
+		d["Length"] = len(v.data)
+		writeObject(w, d)
+		w.WriteString("\nstream\n")
+		w.Write(v.data)
+		w.WriteString("\nendstream")
+	default:
+		panic(fmt.Sprintf("pdf: can't write %T", o))
+	}
+}
@@ -1,62 +1,236 @@
 package pdf
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"strings"
 
 	"github.com/BTBurke/vatinator/img"
 	"github.com/jung-kurt/gofpdf"
 )
 
+// PageSize is the paper size of the invoice packet
+type PageSize string
+
+const (
+	A4     PageSize = "A4"
+	Letter PageSize = "Letter"
+)
+
+// Options control the page layout of the invoice packet
+type Options struct {
+	// PageSize of every page (default: A4, which is what the tax office files)
+	PageSize PageSize
+	// Margin around the page in points
+	Margin float64
+	// OnePerPage puts each receipt on its own page.  Otherwise receipts are tiled left to right and top to
+	// bottom, so several short receipts share a page.
+	OnePerPage bool
+	// Batch and Packet are printed in the footer next to the page number.  They are left out when empty.
+	Batch  string
+	Packet int
+	// Title and Author are saved in the document metadata
+	Title  string
+	Author string
+}
+
+// DefaultOptions tile receipts on A4 with a half inch margin
+var DefaultOptions = Options{
+	PageSize: A4,
+	Margin:   36,
+}
+
+// space between tiled receipts and the space kept for the footer, in points
+const (
+	tileGap      = 12.0
+	footerHeight = 14.0
+)
+
 // PDF handles creating PDFs
 type PDF struct {
 	Name        string
 	p           *gofpdf.Fpdf
 	numReceipts int
+	opts        Options
+
+	// converts UTF-8 to the code page of the core fonts used for the text layer
+	tr func(string) string
+
+	// where the next receipt goes and the height of the current row of receipts
+	x, y      float64
+	rowHeight float64
 }
 
-// NewPDF will create a new PDF handler with filename
+// NewPDF will create a new PDF handler with filename using DefaultOptions
 func NewPDF(fname string) *PDF {
+	return NewPDFWithOptions(fname, nil)
+}
+
+// NewPDFWithOptions is like NewPDF with the page size and layout from opts
+func NewPDFWithOptions(fname string, opts *Options) *PDF {
+	if opts == nil {
+		opts = &DefaultOptions
+	}
+	o := *opts
+	if o.PageSize == "" {
+		o.PageSize = A4
+	}
 	p := &PDF{
-		p:    gofpdf.New("P", "pt", "Letter", ""),
+		p:    gofpdf.New("P", "pt", string(o.PageSize), ""),
 		Name: fname,
+		opts: o,
 	}
-	p.p.SetX(72.0)
-	p.p.SetY(72.0)
+	p.p.SetMargins(o.Margin, o.Margin, o.Margin)
+	p.p.SetAutoPageBreak(false, o.Margin)
+	p.p.AliasNbPages("")
+	p.p.SetFooterFunc(p.footer)
+	p.p.SetCreator("vatinator", true)
+	if o.Title != "" {
+		p.p.SetTitle(o.Title, true)
+	}
+	if o.Author != "" {
+		p.p.SetAuthor(o.Author, true)
+	}
+	p.tr = p.p.UnicodeTranslatorFromDescriptor("")
 	return p
 }
 
-// WriteReceipt will write a receipt to a new page in the PDF
+// footer shows the batch, page X of Y and the packet number at the bottom of each page
+func (p *PDF) footer() {
+	text := fmt.Sprintf("Page %d of {nb}", p.p.PageNo())
+	if p.opts.Batch != "" {
+		text = fmt.Sprintf("Batch %s - %s", p.opts.Batch, text)
+	}
+	if p.opts.Packet > 0 {
+		text = fmt.Sprintf("%s - Packet %d", text, p.opts.Packet)
+	}
+	_, h := p.p.GetPageSize()
+	p.p.SetFont("Helvetica", "", 8)
+	p.p.SetTextColor(96, 96, 96)
+	p.p.SetXY(p.opts.Margin, h-p.opts.Margin-footerHeight+4)
+	p.p.CellFormat(0, footerHeight-4, text, "", 0, "C", false, 0, "")
+}
+
+// place finds where a receipt of w x h points goes, starting a new row or page when it doesn't fit, and returns
+// the position and the scale needed to fit receipts that are bigger than the page
+func (p *PDF) place(w, h float64) (float64, float64, float64) {
+	pageW, pageH := p.p.GetPageSize()
+	left, top := p.opts.Margin, p.opts.Margin
+	right, bottom := pageW-p.opts.Margin, pageH-p.opts.Margin-footerHeight
+
+	scale := 1.0
+	if w > right-left {
+		scale = (right - left) / w
+	}
+	if h*scale > bottom-top {
+		scale = (bottom - top) / h
+	}
+	w, h = w*scale, h*scale
+
+	newPage := p.p.PageNo() == 0 || p.opts.OnePerPage
+	if !newPage && p.x+w > right {
+		// next row
+		p.x = left
+		p.y += p.rowHeight + tileGap
+		p.rowHeight = 0
+	}
+	if newPage || p.y+h > bottom {
+		p.p.AddPage()
+		p.x, p.y, p.rowHeight = left, top, 0
+	}
+
+	x, y := p.x, p.y
+	p.x += w + tileGap
+	if h > p.rowHeight {
+		p.rowHeight = h
+	}
+	return x, y, scale
+}
+
+// WriteReceipt will write a receipt to the PDF, on the current page if there is room
 func (p *PDF) WriteReceipt(image img.Image) error {
+	return p.WriteReceiptWithText(image, nil)
+}
 
-	r, err := image.NewReader()
+// WriteReceiptWithText is like WriteReceipt and adds the words as invisible text over the image, so the text of
+// the receipt can be searched and copied.  Word boxes are in pixels of the image.
+func (p *PDF) WriteReceiptWithText(image img.Image, words []img.Word) error {
+
+	// JPEG is embedded without decoding, PNG has to be recompressed by gofpdf and anything else is converted
+	var data []byte
+	var err error
+	imageType := "JPG"
+	switch image.Format() {
+	case "png":
+		imageType = "PNG"
+		data, err = image.AsPNG()
+	default:
+		data, err = image.AsJPG()
+	}
 	if err != nil {
 		return err
 	}
+	r := bytes.NewReader(data)
 
 	opt := gofpdf.ImageOptions{
-		ImageType:             "PNG",
+		ImageType:             imageType,
 		ReadDpi:               true,
 		AllowNegativePosition: false,
 	}
 
-	p.p.AddPage()
-	itype := p.p.RegisterImageOptionsReader(fmt.Sprintf("k%d", p.numReceipts+1), opt, r)
+	name := fmt.Sprintf("k%d", p.numReceipts+1)
+	itype := p.p.RegisterImageOptionsReader(name, opt, r)
 	if !p.p.Ok() {
 		return fmt.Errorf("error while registering image: %s", p.p.Error())
 	}
 	itype.SetDpi(96.0)
 	w, h := itype.Extent()
 
-	p.p.ImageOptions(fmt.Sprintf("k%d", p.numReceipts+1), 72.0, 72.0, w, h, true, opt, 0, "")
+	x, y, scale := p.place(w, h)
+	p.p.ImageOptions(name, x, y, w*scale, h*scale, false, opt, 0, "")
 	if !p.p.Ok() {
 		return fmt.Errorf("Error while creating receipt pdf file: %s", p.p.Error())
 	}
+	if len(words) > 0 {
+		// pixels to points at the size the image was drawn
+		p.writeText(words, x, y, w*scale/float64(image.Bounds().Dx()))
+		if !p.p.Ok() {
+			return fmt.Errorf("error while writing the text layer: %s", p.p.Error())
+		}
+	}
 	p.numReceipts++
 
 	return nil
 }
 
+// writeText draws each word invisibly in its box.  The font size is chosen so the word is as wide as its box,
+// which makes selections line up with the image.
+func (p *PDF) writeText(words []img.Word, x, y, ptPerPx float64) {
+	p.p.SetTextRenderingMode(3)
+	defer p.p.SetTextRenderingMode(0)
+	for _, word := range words {
+		text := p.tr(word.Text)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		boxW := float64(word.Box.Dx()) * ptPerPx
+		boxH := float64(word.Box.Dy()) * ptPerPx
+		if boxW <= 0 || boxH <= 0 {
+			continue
+		}
+		size := boxH
+		p.p.SetFont("Helvetica", "", size)
+		if sw := p.p.GetStringWidth(text); sw > 0 {
+			size = math.Min(size*boxW/sw, boxH*1.5)
+			p.p.SetFont("Helvetica", "", size)
+		}
+		// text is drawn from the baseline, which is about a fifth of the box above the bottom for most words
+		p.p.Text(x+float64(word.Box.Min.X)*ptPerPx, y+float64(word.Box.Max.Y)*ptPerPx-boxH*0.2, text)
+	}
+}
+
 // Save will write to the PDF file and close it
 func (p *PDF) Save() error {
 	return p.p.OutputFileAndClose(p.Name)
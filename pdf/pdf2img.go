@@ -1,27 +1,61 @@
 package pdf
 
 import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
 	"io"
-	"io/fs"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
 
+	"github.com/BTBurke/vatinator/img"
 	"github.com/pkg/errors"
 )
 
+// PageMode controls how the pages of a multipage PDF are turned into receipt images
+type PageMode int
+
+const (
+	// PagesAuto makes one receipt per page when every page looks like a complete receipt, otherwise stacks them
+	PagesAuto PageMode = iota
+	// PagesStacked appends all pages into one tall image
+	PagesStacked
+	// PagesSeparate makes one receipt per page
+	PagesSeparate
+)
+
+// Limits for stacked mode.  Pages after MaxStackedPages are dropped unless the pages are numbered, and taller
+// images are scaled down so that OCR and the invoice packet don't have to deal with enormous images.
+var (
+	MaxStackedPages  = 6
+	MaxStackedHeight = 12000
+)
+
+// Limit for separate mode to protect against PDFs with zillions of pages
+var MaxPages = 50
+
 // PdfToImage converts a multipage pdf to a single image suitable for OCR like other
 // receipts
-// TODO: Possibly a problem if the PDF has zillions of pages and creates a huge image
 func PdfToImage(r io.ReadCloser) (io.ReadCloser, error) {
-	pdftoppmBin, err := exec.LookPath("pdftoppm")
+	images, err := PdfToImages(r, PagesStacked)
 	if err != nil {
-		return nil, errors.Wrap(err, "requires pdftoppm to convert pdf to image")
+		return nil, err
 	}
-	convertBin, err := exec.LookPath("convert")
+	return images[0], nil
+}
+
+// PdfToImages converts a pdf to one image per receipt according to mode.  Only rasterising the pages needs an
+// external tool (pdftoppm), trimming and stitching the pages happens here.
+func PdfToImages(r io.ReadCloser, mode PageMode) ([]io.ReadCloser, error) {
+	pdftoppmBin, err := exec.LookPath("pdftoppm")
 	if err != nil {
-		return nil, errors.Wrap(err, "requires imagemagick to convert pdf to image")
+		return nil, errors.Wrap(err, "requires pdftoppm to convert pdf to image")
 	}
 
 	// move input pdf to file in tempdir
@@ -29,6 +63,7 @@ func PdfToImage(r io.ReadCloser) (io.ReadCloser, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create tempdir for pdf2img")
 	}
+	defer os.RemoveAll(tmpdir)
 	pdfPath := filepath.Join(tmpdir, "in.pdf")
 	targetPDF, err := os.Create(pdfPath)
 	if err != nil {
@@ -37,41 +72,162 @@ func PdfToImage(r io.ReadCloser) (io.ReadCloser, error) {
 	if _, err := io.Copy(targetPDF, r); err != nil {
 		return nil, errors.Wrap(err, "failed to copy input pdf to temp file in pdf2img")
 	}
+	targetPDF.Close()
+	r.Close()
 
-	// create series of images from pages of PDF
+	// create series of images from pages of PDF.  One page more than the limit is rendered to find out if any were
+	// dropped.
 	outputPngs := filepath.Join(tmpdir, "out")
-	pdfCmd := []string{"-png", "-rx", "300", "-ry", "300", pdfPath, outputPngs}
+	pdfCmd := []string{"-png", "-rx", "300", "-ry", "300", "-l", fmt.Sprintf("%d", MaxPages+1), pdfPath, outputPngs}
 	cmd1 := exec.Command(pdftoppmBin, pdfCmd...)
 	output1, err := cmd1.CombinedOutput()
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to convert pdf to images with output: %s", output1)
 	}
 
-	// convert multiple images to single image
-	// check to see how many pngs there are (total files - 1)
-	files, err := ioutil.ReadDir(tmpdir)
-	if err != nil {
-		files = []fs.FileInfo{}
+	// page numbers are zero padded so the glob is already in page order
+	pages, err := filepath.Glob(filepath.Join(tmpdir, "out*.png"))
+	if err != nil || len(pages) == 0 {
+		return nil, errors.New("pdf has no pages")
 	}
+	if len(pages) > MaxPages {
+		log.Printf("pdf has more than %d pages, only the first %d are used", MaxPages, MaxPages)
+		pages = pages[0:MaxPages]
+	}
+
+	text := pageText(pdfPath)
+	numbered := numberedPages(text)
+	if mode == PagesAuto {
+		mode = autoMode(len(pages), text)
+	}
+
+	// pages are decoded one at a time so a long PDF doesn't hold every 300 DPI page in memory at once
+	switch mode {
+	case PagesSeparate:
+		var images []io.ReadCloser
+		for _, page := range pages {
+			i, err := decodePNG(page)
+			if err != nil {
+				return nil, err
+			}
+			r, err := encodePNG(img.Trim(i))
+			if err != nil {
+				return nil, err
+			}
+			images = append(images, r)
+		}
+		return images, nil
+	default:
+		// a page numbered document keeps every page, like the totals on the last page of a long invoice.  Each page
+		// is shrunk as it is decoded so the stack stays small.
+		pageHeight := 0
+		switch {
+		case len(pages) > MaxStackedPages && numbered:
+			pageHeight = MaxStackedHeight / len(pages)
+		case len(pages) > MaxStackedPages:
+			pages = pages[0:MaxStackedPages]
+		}
+		var stack []image.Image
+		for _, page := range pages {
+			i, err := decodePNG(page)
+			if err != nil {
+				return nil, err
+			}
+			if len(pages) == 2 {
+				// if there are exactly two pages (Telia receipts), rotate them first then append
+				// for a better aspect ratio
+				i = img.RotateCW90(i)
+			}
+			i = img.Trim(i)
+			if pageHeight > 0 {
+				i = img.FitHeight(i, pageHeight)
+			}
+			stack = append(stack, i)
+		}
+		r, err := encodePNG(img.FitHeight(img.Append(stack), MaxStackedHeight))
+		if err != nil {
+			return nil, err
+		}
+		return []io.ReadCloser{r}, nil
+	}
+}
 
-	inputPngs := filepath.Join(tmpdir, "out*.png")
-	outPng := filepath.Join(tmpdir, "result.png")
-	convertCmd := []string{inputPngs, "-trim", "-append", outPng}
-	if len(files) == 3 {
-		// if there are exactly two pngs (Telia receipts), rotate them first then append
-		// for a better aspect ratio
-		convertCmd = []string{inputPngs, "-rotate", "90", "-trim", "-append", outPng}
+func encodePNG(i image.Image) (io.ReadCloser, error) {
+	var b bytes.Buffer
+	if err := png.Encode(&b, i); err != nil {
+		return nil, errors.Wrap(err, "failed to encode the resulting image from pdf2img")
 	}
-	cmd2 := exec.Command(convertBin, convertCmd...)
-	output2, err := cmd2.CombinedOutput()
+	return ioutil.NopCloser(&b), nil
+}
+
+func decodePNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to convert pdf images to single image with output: %s", output2)
+		return nil, errors.Wrap(err, "failed to open pdf page image")
 	}
+	defer f.Close()
+	i, err := png.Decode(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode pdf page image")
+	}
+	return i, nil
+}
 
-	f, err := os.Open(outPng)
+// pageText returns the text layer of each page.  Scanned PDFs have no text layer, so this can be empty.
+func pageText(pdfPath string) []string {
+	bin, err := exec.LookPath("pdftotext")
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get handle to the resulting image from pdf2img")
+		return nil
+	}
+	out, err := exec.Command(bin, "-layout", "-l", fmt.Sprintf("%d", MaxPages), pdfPath, "-").Output()
+	if err != nil {
+		return nil
+	}
+	// pages are separated by form feeds, with one after the last page
+	return strings.Split(strings.TrimSuffix(string(out), "\f"), "\f")
+}
+
+var (
+	pageDate   = regexp.MustCompile(`\b[0-3]?[0-9][./-][01]?[0-9][./-](20)?[0-9]{2}\b|\b20[0-9]{2}-[01][0-9]-[0-3][0-9]\b`)
+	pageTotal  = regexp.MustCompile(`(?i)\b(kokku|summa|total|tasuda|maksta)\b`)
+	pageNumber = regexp.MustCompile(`(?i)\b(lk|lehekülg|leht|page)\.?\s*[0-9]+\s*(/|of|-)\s*[0-9]+\b`)
+)
+
+// autoMode picks the mode for a PDF from its pages.  Page numbers mean one long invoice, which stays one receipt
+// however many pages it has.  Otherwise pages that are each a complete receipt, or too many pages to stack without
+// dropping some, are split.
+func autoMode(numPages int, pages []string) PageMode {
+	switch {
+	case numberedPages(pages):
+		return PagesStacked
+	case numPages > MaxStackedPages || separateReceipts(pages):
+		return PagesSeparate
+	default:
+		return PagesStacked
 	}
+}
 
-	return f, nil
+// numberedPages is true when any page has a page number like "Lk 1/8" or "Page 2 of 3"
+func numberedPages(pages []string) bool {
+	for _, p := range pages {
+		if pageNumber.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// separateReceipts decides if each page is a complete receipt, like a statement with one fuel receipt per page.
+// Every page has to have its own date and total.  Page numbers like "Page 2 of 3" mean that the pages belong
+// to one long invoice.
+func separateReceipts(pages []string) bool {
+	if len(pages) < 2 || numberedPages(pages) {
+		return false
+	}
+	for _, p := range pages {
+		if !pageDate.MatchString(p) || !pageTotal.MatchString(p) {
+			return false
+		}
+	}
+	return true
 }
@@ -2,11 +2,17 @@ package pdf
 
 import (
 	"bytes"
+	"fmt"
+	"image/png"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"testing"
 
 	"github.com/BTBurke/snapshot"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -31,3 +37,91 @@ func TestPDF2Image(t *testing.T) {
 	require.NoError(t, err)
 	snap.Assert(t, b.Bytes())
 }
+
+func TestSeparateReceipts(t *testing.T) {
+	fuel := func(date, total string) string {
+		return "Olerex AS\nDiisel 45,20 L\n" + date + "\nKokku " + total + "\n"
+	}
+	tt := []struct {
+		name  string
+		pages []string
+		out   bool
+	}{
+		{"single page", []string{fuel("02.10.2020", "60,12")}, false},
+		{"fuel statement", []string{fuel("02.10.2020", "60,12"), fuel("09.10.2020", "55,00"), fuel("2020-10-16", "58,40")}, true},
+		{"invoice with page numbers", []string{fuel("02.10.2020", "60,12") + "Lk 1/2", fuel("02.10.2020", "60,12") + "Lk 2/2"}, false},
+		{"second page has details only", []string{fuel("02.10.2020", "60,12"), "Kõnede eristus\n"}, false},
+		{"scanned pdf without text", []string{"", ""}, false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.out, separateReceipts(tc.pages))
+		})
+	}
+}
+
+func TestAutoMode(t *testing.T) {
+	fuel := func(date string) string {
+		return "Olerex AS\n" + date + "\nKokku 60,12\n"
+	}
+	var invoice, scanned []string
+	for i := 1; i <= 8; i++ {
+		invoice = append(invoice, fmt.Sprintf("Telia Eesti AS\nKõnede eristus\nLk %d/8\n", i))
+		scanned = append(scanned, "")
+	}
+	tt := []struct {
+		name     string
+		numPages int
+		pages    []string
+		out      PageMode
+	}{
+		{"page numbered invoice over the stacking limit", 8, invoice, PagesStacked},
+		{"scanned pdf over the stacking limit", 8, scanned, PagesSeparate},
+		{"fuel statement", 2, []string{fuel("02.10.2020"), fuel("09.10.2020")}, PagesSeparate},
+		{"two page invoice", 2, []string{fuel("02.10.2020"), "Kõnede eristus\n"}, PagesStacked},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.out, autoMode(tc.numPages, tc.pages))
+		})
+	}
+}
+
+func TestPdfToImagesNumbered(t *testing.T) {
+	for _, bin := range []string{"pdftoppm", "pdftotext"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("requires %s", bin)
+		}
+	}
+
+	// every page is a black box so trimming keeps the whole box
+	pages := func(numbered bool) io.ReadCloser {
+		p := gofpdf.New("P", "pt", string(A4), "")
+		for i := 1; i <= 8; i++ {
+			p.AddPage()
+			p.SetFillColor(0, 0, 0)
+			p.Rect(40, 40, 500, 750, "F")
+			p.SetFont("Helvetica", "", 12)
+			p.SetTextColor(255, 255, 255)
+			p.Text(60, 80, "Telia Eesti AS")
+			if numbered {
+				p.Text(60, 100, fmt.Sprintf("Lk %d/8", i))
+			}
+		}
+		var b bytes.Buffer
+		require.NoError(t, p.Output(&b))
+		return ioutil.NopCloser(&b)
+	}
+
+	out, err := PdfToImages(pages(true), PagesAuto)
+	require.NoError(t, err)
+	require.Len(t, out, 1)
+	i, err := png.Decode(out[0])
+	require.NoError(t, err)
+	// all 8 pages are stacked, each shrunk to fit
+	assert.InDelta(t, MaxStackedHeight, i.Bounds().Dy(), 8)
+
+	out, err = PdfToImages(pages(false), PagesAuto)
+	require.NoError(t, err)
+	assert.Len(t, out, 8)
+}
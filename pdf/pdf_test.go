@@ -3,6 +3,7 @@ package pdf
 import (
 	"bufio"
 	"bytes"
+	"image"
 	"io"
 	"os"
 	"testing"
@@ -65,3 +66,60 @@ func NopCloser(w io.Writer) io.WriteCloser {
 }
 
 func (nopCloser) Close() error { return nil }
+
+func receiptOfSize(t *testing.T, w, h int) img.Image {
+	i, err := img.NewJPEGFromImage(image.NewRGBA(image.Rect(0, 0, w, h)), 80)
+	require.NoError(t, err)
+	return i
+}
+
+func TestLayout(t *testing.T) {
+	tt := []struct {
+		name  string
+		opts  Options
+		sizes [][2]int
+		pages int
+	}{
+		// 96 dpi pixels, A4 is about 794x1123 px
+		{"small receipts share a page", DefaultOptions, [][2]int{{200, 300}, {200, 300}, {200, 300}, {200, 300}, {200, 300}, {200, 300}}, 1},
+		{"tall receipts get their own page", DefaultOptions, [][2]int{{400, 900}, {400, 900}}, 2},
+		{"one per page", Options{PageSize: Letter, Margin: 72, OnePerPage: true}, [][2]int{{200, 300}, {200, 300}}, 2},
+		{"rows wrap onto a new page", DefaultOptions, [][2]int{{600, 450}, {600, 450}, {600, 450}}, 2},
+		{"too big is scaled to fit", DefaultOptions, [][2]int{{2000, 3000}}, 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.opts
+			opts.Batch = "b1"
+			opts.Packet = 2
+			p := NewPDFWithOptions("", &opts)
+			for _, s := range tc.sizes {
+				require.NoError(t, p.WriteReceipt(receiptOfSize(t, s[0], s[1])))
+			}
+			assert.Equal(t, tc.pages, p.p.PageCount())
+
+			var b bytes.Buffer
+			require.NoError(t, p.Write(NopCloser(&b)))
+		})
+	}
+}
+
+func TestTextLayer(t *testing.T) {
+	p := NewPDFWithOptions("", &Options{PageSize: A4, Margin: 36, Title: "VAT invoices", Author: "Mari Maasikas"})
+	p.p.SetCompression(false)
+	words := []img.Word{
+		{Text: "ARVE-1234", Box: image.Rect(10, 10, 110, 30)},
+		{Text: "Tänan", Box: image.Rect(10, 40, 60, 60)},
+	}
+	require.NoError(t, p.WriteReceiptWithText(receiptOfSize(t, 200, 300), words))
+
+	var b bytes.Buffer
+	require.NoError(t, p.Write(NopCloser(&b)))
+	out := b.String()
+	assert.Contains(t, out, "3 Tr")
+	assert.Contains(t, out, "(ARVE-1234) Tj")
+	// converted to the core font code page
+	assert.Contains(t, out, "(T\xe4nan) Tj")
+	assert.Contains(t, out, "/Title")
+	assert.Contains(t, out, "/Author")
+}
@@ -0,0 +1,452 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io/ioutil"
+)
+
+// document is a parsed PDF file.  Objects are read from the file when they are asked for, so changes to a
+// returned dictionary are only kept if the object is written to an update.
+type document struct {
+	data      []byte
+	xref      map[int]xrefEntry
+	trailer   pdfDict
+	startxref int
+
+	// decoded object streams by object number
+	objStms map[int]*objStm
+}
+
+// xrefEntry is where to find an object: at offset in the file, or at index in the object stream
+type xrefEntry struct {
+	free   bool
+	offset int
+	stream int
+	index  int
+	gen    int
+}
+
+type objStm struct {
+	data    []byte
+	first   int
+	offsets map[int]int
+}
+
+func readDocument(data []byte) (*document, error) {
+	d := &document{
+		data:    data,
+		xref:    make(map[int]xrefEntry),
+		trailer: pdfDict{},
+		objStms: make(map[int]*objStm),
+	}
+	i := bytes.LastIndex(data, []byte("startxref"))
+	if i < 0 {
+		return nil, fmt.Errorf("not a PDF file: no startxref")
+	}
+	p := &parser{b: data, pos: i + len("startxref")}
+	start, err := p.int()
+	if err != nil {
+		return nil, err
+	}
+	d.startxref = start
+
+	// sections are read newest first and the first entry found for an object wins
+	seen := make(map[int]bool)
+	for off := start; off > 0 && !seen[off]; {
+		seen[off] = true
+		trailer, err := d.readXref(off)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cross reference at offset %d: %v", off, err)
+		}
+		for k, v := range trailer {
+			if _, ok := d.trailer[k]; !ok {
+				d.trailer[k] = v
+			}
+		}
+		// hybrid files keep the newer objects in a stream next to the table
+		if stm, ok := trailer["XRefStm"].(int); ok && !seen[stm] {
+			seen[stm] = true
+			if _, err := d.readXref(stm); err != nil {
+				return nil, err
+			}
+		}
+		off, _ = trailer["Prev"].(int)
+	}
+	if _, ok := d.trailer["Root"].(pdfRef); !ok {
+		return nil, fmt.Errorf("PDF has no document catalog")
+	}
+	return d, nil
+}
+
+// readXref reads one cross reference table or stream and returns its trailer
+func (d *document) readXref(off int) (pdfDict, error) {
+	p := &parser{b: d.data, pos: off}
+	p.skipSpace()
+	if !bytes.HasPrefix(d.data[p.pos:], []byte("xref")) {
+		return d.readXrefStream(off)
+	}
+	p.pos += len("xref")
+	for {
+		save := p.pos
+		if p.token() == "trailer" {
+			return p.dict0()
+		}
+		p.pos = save
+		first, err := p.int()
+		if err != nil {
+			return nil, err
+		}
+		count, err := p.int()
+		if err != nil {
+			return nil, err
+		}
+		for n := first; n < first+count; n++ {
+			offset, err := p.int()
+			if err != nil {
+				return nil, err
+			}
+			gen, err := p.int()
+			if err != nil {
+				return nil, err
+			}
+			kind := p.token()
+			if _, ok := d.xref[n]; !ok {
+				d.xref[n] = xrefEntry{free: kind == "f", offset: offset, gen: gen}
+			}
+		}
+	}
+}
+
+// dict0 reads a dictionary starting at the next <<
+func (p *parser) dict0() (pdfDict, error) {
+	o, err := p.object()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := o.(pdfDict)
+	if !ok {
+		return nil, fmt.Errorf("expected a dictionary at offset %d", p.pos)
+	}
+	return d, nil
+}
+
+func (d *document) readXrefStream(off int) (pdfDict, error) {
+	_, o, err := d.indirect(off)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.(pdfStream)
+	if !ok || s.dict["Type"] != pdfName("XRef") {
+		return nil, fmt.Errorf("no cross reference stream")
+	}
+	data, err := d.decode(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var w []int
+	wa, _ := s.dict["W"].(pdfArray)
+	for _, v := range wa {
+		n, _ := v.(int)
+		w = append(w, n)
+	}
+	if len(w) != 3 {
+		return nil, fmt.Errorf("bad W in cross reference stream")
+	}
+	index, _ := s.dict["Index"].(pdfArray)
+	if index == nil {
+		index = pdfArray{0, s.dict["Size"]}
+	}
+
+	field := func(b []byte, def int) int {
+		if len(b) == 0 {
+			return def
+		}
+		v := 0
+		for _, c := range b {
+			v = v<<8 | int(c)
+		}
+		return v
+	}
+	row := w[0] + w[1] + w[2]
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		first, _ := index[i].(int)
+		count, _ := index[i+1].(int)
+		for n := first; n < first+count; n++ {
+			if pos+row > len(data) {
+				return nil, fmt.Errorf("cross reference stream is too short")
+			}
+			b := data[pos : pos+row]
+			pos += row
+			kind := field(b[:w[0]], 1)
+			f2 := field(b[w[0]:w[0]+w[1]], 0)
+			f3 := field(b[w[0]+w[1]:], 0)
+			if _, ok := d.xref[n]; ok {
+				continue
+			}
+			switch kind {
+			case 0:
+				d.xref[n] = xrefEntry{free: true}
+			case 1:
+				d.xref[n] = xrefEntry{offset: f2, gen: f3}
+			case 2:
+				d.xref[n] = xrefEntry{stream: f2, index: f3}
+			}
+		}
+	}
+	return s.dict, nil
+}
+
+// indirect reads the object defined at off
+func (d *document) indirect(off int) (pdfRef, interface{}, error) {
+	p := &parser{b: d.data, pos: off}
+	num, err := p.int()
+	if err != nil {
+		return pdfRef{}, nil, err
+	}
+	gen, err := p.int()
+	if err != nil {
+		return pdfRef{}, nil, err
+	}
+	if tok := p.token(); tok != "obj" {
+		return pdfRef{}, nil, fmt.Errorf("expected obj at offset %d, got %q", p.pos, tok)
+	}
+	o, err := p.object()
+	if err != nil {
+		return pdfRef{}, nil, err
+	}
+	ref := pdfRef{num, gen}
+
+	save := p.pos
+	if p.token() != "stream" {
+		p.pos = save
+		return ref, o, nil
+	}
+	dict, ok := o.(pdfDict)
+	if !ok {
+		return ref, nil, fmt.Errorf("stream without a dictionary in object %d", num)
+	}
+	// the data starts after the end of line following the keyword
+	if p.pos < len(d.data) && d.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(d.data) && d.data[p.pos] == '\n' {
+		p.pos++
+	}
+	length, ok := dict["Length"].(int)
+	if r, isRef := dict["Length"].(pdfRef); isRef {
+		l, err := d.object(r.num)
+		if err != nil {
+			return ref, nil, err
+		}
+		length, ok = l.(int)
+	}
+	if !ok || p.pos+length > len(d.data) {
+		return ref, nil, fmt.Errorf("bad stream length in object %d", num)
+	}
+	return ref, pdfStream{dict: dict, data: d.data[p.pos : p.pos+length]}, nil
+}
+
+// object reads object num from the file or from its object stream
+func (d *document) object(num int) (interface{}, error) {
+	e, ok := d.xref[num]
+	if !ok || e.free {
+		return nil, nil
+	}
+	if e.stream == 0 {
+		ref, o, err := d.indirect(e.offset)
+		if err != nil {
+			return nil, err
+		}
+		if ref.num != num {
+			return nil, fmt.Errorf("expected object %d at offset %d, found %d", num, e.offset, ref.num)
+		}
+		return o, nil
+	}
+
+	stm, err := d.objStm(e.stream)
+	if err != nil {
+		return nil, err
+	}
+	off, ok := stm.offsets[num]
+	if !ok {
+		return nil, fmt.Errorf("object %d is not in object stream %d", num, e.stream)
+	}
+	p := &parser{b: stm.data, pos: stm.first + off}
+	return p.object()
+}
+
+func (d *document) objStm(num int) (*objStm, error) {
+	if stm, ok := d.objStms[num]; ok {
+		return stm, nil
+	}
+	o, err := d.object(num)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := o.(pdfStream)
+	if !ok {
+		return nil, fmt.Errorf("object %d is not an object stream", num)
+	}
+	data, err := d.decode(s)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := s.dict["N"].(int)
+	first, _ := s.dict["First"].(int)
+	stm := &objStm{data: data, first: first, offsets: make(map[int]int)}
+	p := &parser{b: data}
+	for i := 0; i < n; i++ {
+		obj, err := p.int()
+		if err != nil {
+			return nil, err
+		}
+		off, err := p.int()
+		if err != nil {
+			return nil, err
+		}
+		stm.offsets[obj] = off
+	}
+	d.objStms[num] = stm
+	return stm, nil
+}
+
+// resolve follows references until it gets to a direct object
+func (d *document) resolve(o interface{}) (interface{}, error) {
+	for i := 0; i < 32; i++ {
+		r, ok := o.(pdfRef)
+		if !ok {
+			return o, nil
+		}
+		var err error
+		if o, err = d.object(r.num); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("too many levels of references")
+}
+
+// dict resolves o to a dictionary, or nil if it isn't one
+func (d *document) dict(o interface{}) pdfDict {
+	o, err := d.resolve(o)
+	if err != nil {
+		return nil
+	}
+	dict, _ := o.(pdfDict)
+	return dict
+}
+
+// decode removes the filters from the stream data.  Only Flate is supported, which is what every form template
+// uses.
+func (d *document) decode(s pdfStream) ([]byte, error) {
+	filter, err := d.resolve(s.dict["Filter"])
+	if err != nil {
+		return nil, err
+	}
+	parms := d.dict(s.dict["DecodeParms"])
+	if a, ok := filter.(pdfArray); ok {
+		switch len(a) {
+		case 0:
+			filter = nil
+		case 1:
+			filter = a[0]
+			if pa, ok := s.dict["DecodeParms"].(pdfArray); ok && len(pa) == 1 {
+				parms = d.dict(pa[0])
+			}
+		default:
+			return nil, fmt.Errorf("more than one stream filter is not supported")
+		}
+	}
+	switch filter {
+	case nil:
+		return s.data, nil
+	case pdfName("FlateDecode"):
+	default:
+		return nil, fmt.Errorf("unsupported stream filter %v", filter)
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(s.data))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	predictor, _ := parms["Predictor"].(int)
+	if predictor < 10 {
+		return data, nil
+	}
+	columns, _ := parms["Columns"].(int)
+	colors, _ := parms["Colors"].(int)
+	bpc, _ := parms["BitsPerComponent"].(int)
+	if columns == 0 {
+		columns = 1
+	}
+	if colors == 0 {
+		colors = 1
+	}
+	if bpc == 0 {
+		bpc = 8
+	}
+	return unpredict(data, (columns*colors*bpc+7)/8, (colors*bpc+7)/8)
+}
+
+// unpredict reverses the PNG predictors, where each row starts with a byte for the filter type
+func unpredict(data []byte, width int, bpp int) ([]byte, error) {
+	var out []byte
+	prev := make([]byte, width)
+	for len(data) > 0 {
+		if len(data) < width+1 {
+			return nil, fmt.Errorf("predicted stream is too short")
+		}
+		filter, row := data[0], append([]byte(nil), data[1:width+1]...)
+		data = data[width+1:]
+		for i := range row {
+			var left, upleft byte
+			if i >= bpp {
+				left, upleft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upleft)
+			default:
+				return nil, fmt.Errorf("unknown PNG predictor %d", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
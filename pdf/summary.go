@@ -0,0 +1,234 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Summary is the report of a batch that goes in the zip with the forms.  It lists every receipt with where it
+// landed on the forms and the ones that were left off or need a second look.
+type Summary struct {
+	Name   string
+	Period string
+	Batch  string
+	// Receipts are on the forms, in form order
+	Receipts []SummaryLine
+	// Excluded are left off the forms
+	Excluded []SummaryLine
+	// Totals of the receipts on the forms
+	Total  string
+	VAT    string
+	Excise string
+}
+
+// SummaryLine is one receipt in the summary
+type SummaryLine struct {
+	Filename      string
+	Vendor        string
+	TaxID         string
+	Date          string
+	ReceiptNumber string
+	Total         string
+	VAT           string
+	ExciseType    string
+	ExciseAmount  string
+	ExciseTax     string
+	// Forms is where the receipt is, like VAT 1 line 3
+	Forms []string
+	// Reason the receipt was left off the forms
+	Reason string
+	// Problems are errors and warnings from processing
+	Problems []string
+	// Thumbnail is a small JPEG of the receipt
+	Thumbnail []byte
+}
+
+// NeedsAttention returns the receipts on the forms that had problems, so they can be checked before submitting
+func (s Summary) NeedsAttention() []SummaryLine {
+	var out []SummaryLine
+	for _, l := range s.Receipts {
+		if len(l.Problems) > 0 {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+const summaryTitle = "Receipt summary"
+
+// WriteSummary renders the summary as an A4 PDF at path
+func WriteSummary(path string, s Summary) error {
+	p, err := renderSummary(s)
+	if err != nil {
+		return err
+	}
+	return p.OutputFileAndClose(path)
+}
+
+// WriteSummaryTo is like WriteSummary but writes to w
+func WriteSummaryTo(w io.Writer, s Summary) error {
+	p, err := renderSummary(s)
+	if err != nil {
+		return err
+	}
+	return p.Output(w)
+}
+
+func renderSummary(s Summary) (*gofpdf.Fpdf, error) {
+	const margin = 36.0
+	const thumbWidth, thumbHeight = 72.0, 108.0
+	p := gofpdf.New("P", "pt", string(A4), "")
+	p.SetMargins(margin, margin, margin)
+	p.SetAutoPageBreak(false, margin)
+	p.SetTitle(summaryTitle, true)
+	p.SetAuthor(s.Name, true)
+	p.SetCreator("vatinator", true)
+	tr := p.UnicodeTranslatorFromDescriptor("")
+	width, height := p.GetPageSize()
+	width -= 2 * margin
+	p.SetFooterFunc(func() {
+		p.SetY(-margin + 8)
+		p.SetFont("Helvetica", "", 7)
+		p.CellFormat(width, 10, fmt.Sprintf("%s %s - page %d", summaryTitle, s.Batch, p.PageNo()), "", 0, "C", false, 0, "")
+	})
+	p.AddPage()
+
+	// starts a new page if h points don't fit on this one
+	need := func(h float64) {
+		if p.GetY()+h > height-margin {
+			p.AddPage()
+		}
+	}
+
+	p.SetFont("Helvetica", "B", 14)
+	p.CellFormat(width, 18, tr(summaryTitle), "", 1, "L", false, 0, "")
+	p.SetFont("Helvetica", "", 9)
+	for _, kv := range [][2]string{{"Name", s.Name}, {"Period", s.Period}, {"Batch", s.Batch}} {
+		if kv[1] == "" {
+			continue
+		}
+		p.CellFormat(60, 12, kv[0], "", 0, "L", false, 0, "")
+		p.CellFormat(width-60, 12, fit(p, tr(kv[1]), width-60), "", 1, "L", false, 0, "")
+	}
+	p.Ln(6)
+
+	attention := s.NeedsAttention()
+	p.SetFont("Helvetica", "B", 10)
+	p.CellFormat(width, 14, "Totals", "B", 1, "L", false, 0, "")
+	p.SetFont("Helvetica", "", 9)
+	for _, kv := range [][2]string{
+		{"Receipts on the forms", fmt.Sprintf("%d", len(s.Receipts))},
+		{"Total incl. VAT", s.Total},
+		{"VAT", s.VAT},
+		{"Excise", s.Excise},
+		{"Need attention", fmt.Sprintf("%d", len(attention))},
+		{"Left off the forms", fmt.Sprintf("%d", len(s.Excluded))},
+	} {
+		p.CellFormat(120, 12, kv[0], "", 0, "L", false, 0, "")
+		p.CellFormat(80, 12, kv[1], "", 1, "R", false, 0, "")
+	}
+	p.Ln(8)
+
+	thumbs := make(map[*byte]string)
+	line := func(l SummaryLine) error {
+		var text []string
+		add := func(label, value string) {
+			if value != "" {
+				text = append(text, fmt.Sprintf("%s: %s", label, value))
+			}
+		}
+		add("Vendor", l.Vendor)
+		add("Tax ID", l.TaxID)
+		add("Date", l.Date)
+		add("Receipt", l.ReceiptNumber)
+		add("Total", l.Total)
+		add("VAT", l.VAT)
+		if l.ExciseType != "" || l.ExciseAmount != "" {
+			add("Excise", strings.TrimSpace(fmt.Sprintf("%s %s, tax %s", l.ExciseType, l.ExciseAmount, l.ExciseTax)))
+		}
+		add("Forms", strings.Join(l.Forms, ", "))
+		add("Left off", l.Reason)
+
+		textHeight := 14 + 11*float64(len(text)+len(l.Problems))
+		rowHeight := textHeight
+		if len(l.Thumbnail) > 0 && rowHeight < thumbHeight {
+			rowHeight = thumbHeight
+		}
+		need(rowHeight + 8)
+		y := p.GetY()
+		x := margin + thumbWidth + 10
+
+		if len(l.Thumbnail) > 0 {
+			// receipts that need attention are listed twice but the thumbnail is only added once
+			opt := gofpdf.ImageOptions{ImageType: "JPG"}
+			name, ok := thumbs[&l.Thumbnail[0]]
+			if !ok {
+				name = fmt.Sprintf("t%d", len(thumbs)+1)
+				thumbs[&l.Thumbnail[0]] = name
+			}
+			info := p.RegisterImageOptionsReader(name, opt, bytes.NewReader(l.Thumbnail))
+			if !p.Ok() {
+				return fmt.Errorf("failed to add thumbnail for %s: %s", l.Filename, p.Error())
+			}
+			w, h := info.Width(), info.Height()
+			scale := thumbWidth / w
+			if h*scale > thumbHeight {
+				scale = thumbHeight / h
+			}
+			p.ImageOptions(name, margin, y, w*scale, h*scale, false, opt, 0, "")
+		}
+
+		p.SetXY(x, y)
+		p.SetFont("Helvetica", "B", 9)
+		p.CellFormat(width-x+margin, 14, fit(p, tr(l.Filename), width-x+margin), "", 2, "L", false, 0, "")
+		p.SetFont("Helvetica", "", 8.5)
+		for _, t := range text {
+			p.CellFormat(width-x+margin, 11, fit(p, tr(t), width-x+margin), "", 2, "L", false, 0, "")
+		}
+		p.SetTextColor(180, 0, 0)
+		for _, t := range l.Problems {
+			p.CellFormat(width-x+margin, 11, fit(p, tr(t), width-x+margin), "", 2, "L", false, 0, "")
+		}
+		p.SetTextColor(0, 0, 0)
+
+		p.SetY(y + rowHeight + 4)
+		p.Line(margin, p.GetY(), margin+width, p.GetY())
+		p.SetY(p.GetY() + 4)
+		return nil
+	}
+	section := func(title string, lines []SummaryLine) error {
+		if len(lines) == 0 {
+			return nil
+		}
+		need(40)
+		p.SetFont("Helvetica", "B", 10)
+		p.CellFormat(width, 14, tr(title), "B", 1, "L", false, 0, "")
+		p.Ln(4)
+		for _, l := range lines {
+			if err := line(l); err != nil {
+				return err
+			}
+		}
+		p.Ln(8)
+		return nil
+	}
+
+	if err := section("Need attention", attention); err != nil {
+		return nil, err
+	}
+	if err := section("Left off the forms", s.Excluded); err != nil {
+		return nil, err
+	}
+	if err := section("Receipts on the forms", s.Receipts); err != nil {
+		return nil, err
+	}
+
+	if !p.Ok() {
+		return nil, fmt.Errorf("failed to render summary: %s", p.Error())
+	}
+	return p, nil
+}
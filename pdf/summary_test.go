@@ -0,0 +1,50 @@
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryText(t *testing.T) {
+	thumb := image.NewGray(image.Rect(0, 0, 30, 60))
+	for i := range thumb.Pix {
+		thumb.Pix[i] = 200
+	}
+	thumb.Set(5, 5, color.Black)
+	var jpg bytes.Buffer
+	require.NoError(t, jpeg.Encode(&jpg, thumb, nil))
+
+	var receipts []SummaryLine
+	for i := 0; i < 12; i++ {
+		receipts = append(receipts, SummaryLine{Filename: "rimi.jpg", Vendor: "Rimi", Total: "12.00", VAT: "2.00", Forms: []string{"VAT 1 line 1"}, Thumbnail: jpg.Bytes()})
+	}
+	receipts[3].Problems = []string{"warning: blurry"}
+	p, err := renderSummary(Summary{
+		Name:     "Jüri Õunapuu",
+		Period:   "JANUARY 2021",
+		Batch:    "b1",
+		Receipts: receipts,
+		Excluded: []SummaryLine{{Filename: "slip.jpg", Reason: "not a receipt or invoice (card slip)"}},
+		Total:    "144.00",
+		VAT:      "24.00",
+		Excise:   "0.00",
+	})
+	require.NoError(t, err)
+	p.SetCompression(false)
+	assert.True(t, p.PageCount() > 1)
+
+	var b bytes.Buffer
+	require.NoError(t, p.Output(&b))
+	out := b.String()
+	for _, s := range []string{"(J\xfcri \xd5unapuu)", "(144.00)", "(warning: blurry)", "(slip.jpg)", "(Forms: VAT 1 line 1)"} {
+		assert.Contains(t, out, s)
+	}
+	// the receipt that needs attention is listed twice but its thumbnail is only embedded once
+	assert.Equal(t, 1, bytes.Count(b.Bytes(), []byte("/Subtype /Image")))
+}
@@ -0,0 +1,219 @@
+package pdf
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// VATForm is one VAT refund application.  The layout mirrors assets/vat-template.xlsx for people who can't open
+// the spreadsheet.
+type VATForm struct {
+	Name         string
+	DiplomaticID string
+	Bank         string
+	Month        int
+	Year         int
+	Lines        []VATFormLine
+	// MaxLines is the number of rows in the receipt table, from the layout of the spreadsheet.  When it's 0 the
+	// table only has the lines.
+	MaxLines int
+	// Total and VAT are the sums of the lines, formatted like the lines
+	Total string
+	VAT   string
+}
+
+// VATFormLine is a receipt on the form.  Amounts are formatted with a decimal point.
+type VATFormLine struct {
+	Vendor string
+	Number string
+	Date   string
+	Total  string
+	VAT    string
+}
+
+// labels from the official template
+const (
+	vatTitle       = "TAOTLUS KÄIBEMAKSU TAGASTAMISEKS"
+	vatTitleEN     = "APPLICATION FOR REFUND OF VALUE ADDED TAX"
+	vatApplicantEE = "Diplomaadile, konsulaarametnikule (v.a aukonsul), erimissiooni ja Välisministeeriumi tunnustatud rahvusvahelise organisatsiooni esindajale või esindusele, haldustöötajale (v.a ühenduse institutsiooni haldustöötaja), välisesindusele, konsulaarasutusele, erimissioonile ja ühenduse institutsioonile, NATO liikmesriigi (v.a Eesti relvajõud) ja muu välisriigi relvajõududele ning nendega kaasas olevale tsiviilkoosseisule ja liikmetele, rahvusvahelisele sõjalisele peakorterile ning rahvusvahelisele sõjalisele õppeasutusele"
+	vatName        = "Taotleja nimi / Applicant's name"
+	vatDipNumber   = "Diplomaadi- või teenistuskaardi nr. / Diplomatic or Service Card No."
+	vatReceiver    = "Käibemaksutagastuse saaja nimi / Name of receiver of refund"
+	vatBank        = "Tagastuse saaja panga nimi, kood ja aadress, pangakonto nr / Name, code and address of the bank of the reciever of refund, account no."
+	vatPeriod      = "Periood, mille eest käibemaksu tagastamist taotletakse (kuu, aasta) / Refund of VAT is requested for (month, year)"
+	vatTotal       = "Kokku/Total:"
+	vatSignature   = "Asutuse või esinduse juhi või välisriigi relvajõudude esindaja allkiri ning asutuse või esinduse või relvajõudude pitsati jäljend / Signature of the Head of Institution or Mission or Representative of armed forces of foreign states and Official Seal of the Institution or Mission or armed forces"
+	vatMFA         = "Täidab Välisministeerium või Kaitseministeerium / To be completed by the MFA or MOD"
+)
+
+// columns of the receipt table with their headers
+var vatColumns = []struct {
+	width  float64
+	header string
+}{
+	{28, "Jrk Nr/ No"},
+	{150, "Müüja / Vendor"},
+	{85, "Arve nr / Number of Invoice"},
+	{62, "Arve kuupäev / Date of Invoice"},
+	{70, "Summa koos käibemaksuga eurodes / Total value, incl. VAT, in euro"},
+	{60, "Käibemaks eurodes / VAT, euro"},
+	{68, "Täidab Maksuhaldur/ To be completed by the Tax Authority Tagastatav summa/ Refundable amount"},
+}
+
+// WriteVATForm renders the form as an A4 PDF at path
+func WriteVATForm(path string, form VATForm) error {
+	p, err := renderVATForm(form)
+	if err != nil {
+		return err
+	}
+	return p.OutputFileAndClose(path)
+}
+
+// WriteVATFormTo is like WriteVATForm but writes to w
+func WriteVATFormTo(w io.Writer, form VATForm) error {
+	p, err := renderVATForm(form)
+	if err != nil {
+		return err
+	}
+	return p.Output(w)
+}
+
+func renderVATForm(form VATForm) (*gofpdf.Fpdf, error) {
+	rows := form.MaxLines
+	if rows == 0 {
+		rows = len(form.Lines)
+	}
+	if len(form.Lines) > rows {
+		return nil, fmt.Errorf("VAT form has %d lines, the maximum is %d", len(form.Lines), rows)
+	}
+
+	const margin = 36.0
+	p := gofpdf.New("P", "pt", string(A4), "")
+	p.SetMargins(margin, margin, margin)
+	p.SetAutoPageBreak(false, margin)
+	p.SetTitle(vatTitleEN, true)
+	p.SetAuthor(form.Name, true)
+	p.SetCreator("vatinator", true)
+	tr := p.UnicodeTranslatorFromDescriptor("")
+	p.AddPage()
+	width, height := p.GetPageSize()
+	width -= 2 * margin
+
+	p.SetFont("Helvetica", "B", 13)
+	p.CellFormat(width, 16, tr(vatTitle), "", 1, "C", false, 0, "")
+	p.SetFont("Helvetica", "B", 11)
+	p.CellFormat(width, 14, tr(vatTitleEN), "", 1, "C", false, 0, "")
+	p.Ln(6)
+	p.SetFont("Helvetica", "", 7)
+	p.MultiCell(width, 9, tr(vatApplicantEE), "", "L", false)
+	p.Ln(6)
+
+	// header fields are a small label over a boxed value
+	field := func(x, w float64, label, value string) {
+		y := p.GetY()
+		p.SetXY(x, y)
+		p.SetFont("Helvetica", "", 7)
+		p.CellFormat(w, 10, fit(p, tr(label), w), "", 0, "L", false, 0, "")
+		p.SetXY(x, y+10)
+		p.SetFont("Helvetica", "", 10)
+		p.CellFormat(w, 18, fit(p, tr(value), w-4), "1", 0, "L", false, 0, "")
+		p.SetXY(margin, y)
+	}
+	row := func() { p.SetY(p.GetY() + 34) }
+
+	field(margin, width*0.55-6, vatName, form.Name)
+	field(margin+width*0.55, width*0.45, vatDipNumber, form.DiplomaticID)
+	row()
+	field(margin, width, vatReceiver, form.Name)
+	row()
+	field(margin, width, vatBank, form.Bank)
+	row()
+	period := ""
+	if form.Year > 0 && form.Month > 0 {
+		period = time.Date(form.Year, time.Month(form.Month), 1, 0, 0, 0, 0, time.UTC).Format("Jan-06")
+	}
+	field(margin, width, vatPeriod, period)
+	row()
+	p.Ln(6)
+
+	// receipt table.  Rows get shorter when a layout has more lines than fit on the page, leaving room for the
+	// totals and the signature boxes.
+	x, y := margin, p.GetY()
+	const headerHeight, footerHeight = 44.0, 146.0
+	lineHeight := 17.0
+	if h := (height - margin - footerHeight - headerHeight - y) / float64(rows+1); h < lineHeight {
+		lineHeight = h
+	}
+	p.SetFont("Helvetica", "B", 6.5)
+	for _, c := range vatColumns {
+		p.Rect(x, y, c.width, headerHeight, "D")
+		p.SetXY(x+1, y+2)
+		p.MultiCell(c.width-2, 7.5, tr(c.header), "", "C", false)
+		x += c.width
+	}
+	y += headerHeight
+
+	p.SetFont("Helvetica", "", 8.5)
+	for i := 0; i < rows; i++ {
+		var cells []string
+		if i < len(form.Lines) {
+			l := form.Lines[i]
+			cells = []string{fmt.Sprintf("%d", i+1), l.Vendor, l.Number, l.Date, l.Total, l.VAT, ""}
+		} else {
+			cells = make([]string, len(vatColumns))
+		}
+		x = margin
+		for n, c := range vatColumns {
+			align := "L"
+			if n == 0 || n >= 4 {
+				align = "R"
+			}
+			p.SetXY(x, y)
+			p.CellFormat(c.width, lineHeight, fit(p, tr(cells[n]), c.width-4), "1", 0, align, false, 0, "")
+			x += c.width
+		}
+		y += lineHeight
+	}
+
+	// totals under the amount columns
+	x = margin
+	for _, c := range vatColumns[:3] {
+		x += c.width
+	}
+	p.SetXY(x, y)
+	p.SetFont("Helvetica", "B", 8.5)
+	p.CellFormat(vatColumns[3].width, lineHeight, tr(vatTotal), "1", 0, "R", false, 0, "")
+	p.CellFormat(vatColumns[4].width, lineHeight, form.Total, "1", 0, "R", false, 0, "")
+	p.CellFormat(vatColumns[5].width, lineHeight, form.VAT, "1", 0, "R", false, 0, "")
+	y += lineHeight + 18
+
+	// signature and stamp boxes
+	half := width/2 - 6
+	p.SetFont("Helvetica", "", 7)
+	p.SetXY(margin, y)
+	p.MultiCell(half, 9, tr(vatSignature), "", "L", false)
+	p.SetXY(margin+width/2+6, y)
+	p.MultiCell(half, 9, tr(vatMFA), "", "L", false)
+	p.Rect(margin, y+48, half, 80, "D")
+	p.Rect(margin+width/2+6, y+48, half, 80, "D")
+
+	if !p.Ok() {
+		return nil, fmt.Errorf("failed to render VAT form: %s", p.Error())
+	}
+	return p, nil
+}
+
+// fit shortens s with an ellipsis until it fits in w points at the current font.  s is already translated to the
+// single byte code page of the core fonts, so it is cut by bytes.
+func fit(p *gofpdf.Fpdf, s string, w float64) string {
+	if p.GetStringWidth(s) <= w {
+		return s
+	}
+	for len(s) > 0 && p.GetStringWidth(s+"...") > w {
+		s = s[:len(s)-1]
+	}
+	return s + "..."
+}
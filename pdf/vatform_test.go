@@ -0,0 +1,65 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVATForm(t *testing.T) {
+	form := VATForm{
+		Name:         "Mari Maasikas",
+		DiplomaticID: "12345",
+		Bank:         "Swedbank EE123456789",
+		Month:        1,
+		Year:         2021,
+		Total:        "30.000",
+		VAT:          "5.000",
+		MaxLines:     17,
+	}
+	for i := 0; i < form.MaxLines; i++ {
+		form.Lines = append(form.Lines, VATFormLine{
+			Vendor: fmt.Sprintf("Rimi Eesti Food AS %d", i),
+			Number: fmt.Sprintf("A-%d", i),
+			Date:   "02/01/2021",
+			Total:  "1.765",
+			VAT:    "0.294",
+		})
+	}
+
+	var b bytes.Buffer
+	require.NoError(t, WriteVATFormTo(&b, form))
+	assert.True(t, bytes.HasPrefix(b.Bytes(), []byte("%PDF")))
+
+	form.Lines = append(form.Lines, VATFormLine{Vendor: "one too many"})
+	assert.Error(t, WriteVATFormTo(&b, form))
+
+	// layouts with more lines than the official form still fit on the page
+	form.MaxLines = 40
+	p, err := renderVATForm(form)
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.PageCount())
+}
+
+func TestVATFormText(t *testing.T) {
+	p, err := renderVATForm(VATForm{
+		Name:  "Jüri Õunapuu",
+		Month: 2,
+		Year:  2021,
+		Lines: []VATFormLine{{Vendor: "Selver", Number: "123", Date: "03/02/2021", Total: "12.00", VAT: "2.00"}},
+		Total: "12.00",
+		VAT:   "2.00",
+	})
+	require.NoError(t, err)
+	p.SetCompression(false)
+
+	var b bytes.Buffer
+	require.NoError(t, p.Output(&b))
+	out := b.String()
+	for _, s := range []string{"(Selver)", "(03/02/2021)", "(12.00)", "(Feb-21)", "(J\xfcri \xd5unapuu)", "Kokku/Total:"} {
+		assert.Contains(t, out, s)
+	}
+}
@@ -13,8 +13,9 @@ import (
 	"time"
 
 	"github.com/BTBurke/clt"
-	"github.com/BTBurke/vatinator/bundled"
+	"github.com/BTBurke/vatinator/einvoice"
 	"github.com/BTBurke/vatinator/img"
+	"github.com/BTBurke/vatinator/inbox"
 	"github.com/BTBurke/vatinator/pdf"
 	"github.com/BTBurke/vatinator/svc"
 	"github.com/dgraph-io/badger/v2"
@@ -27,7 +28,19 @@ type Options struct {
 	CredentialPath string
 	OutputPath     string
 	Interactive    bool
-	log            *log.Logger
+	// Preprocess prepares images before OCR, nil uses img.DefaultPreprocessOptions
+	Preprocess *img.PreprocessOptions
+	// Quality sets photo quality limits and whether hopeless photos are rejected, nil uses img.DefaultQualityOptions
+	Quality *img.QualityOptions
+	// PDFForms also writes the VAT forms as PDF for people who can't open xlsx
+	PDFForms bool
+	// DataFormats also exports the receipt data as CSV or JSON for bookkeeping
+	DataFormats []svc.DataFormat
+	log         *log.Logger
+
+	// photo quality warnings collected during processing for the error email
+	mu       sync.Mutex
+	warnings []string
 }
 
 // ProcessService queues an async processing request for the web version.  CLI version calls
@@ -140,7 +153,9 @@ func (p *processService) Do(id AccountID, batch string, month string, year int)
 		var b bytes.Buffer
 		logWriter := io.MultiWriter(&b, os.Stdout)
 		opts.log.SetOutput(logWriter)
-		handleError := func() { _ = p.email.SendErrorEmail(address, EmailData{RunLog: b.String()}) }
+		handleError := func() {
+			_ = p.email.SendErrorEmail(address, EmailData{RunLog: b.String(), Warnings: opts.warnings})
+		}
 
 		if err := Process(path, fd, month, year, opts); err != nil {
 			opts.log.Printf("process failed: %v", err)
@@ -160,8 +175,21 @@ func (p *processService) Do(id AccountID, batch string, month string, year int)
 			return
 		}
 		opts.log.Printf("Found %d files to zip", len(files))
-		// Files likes Burke-December2020-<batchID>.zip
-		zipName := fmt.Sprintf("%s-%s%d-%s.zip", fd.LastName, month, year, batch)
+		// Files likes USA-Burke-December2020-<batchID>.zip
+		zipName, err := svc.FileName{
+			Country: fd.Country,
+			First:   fd.FirstName,
+			Last:    fd.LastName,
+			Month:   month,
+			Year:    year,
+			Batch:   batch,
+		}.ArchiveName(fd.FileNames)
+		if err != nil {
+			opts.log.Printf("zip file name failed: %v", err)
+			handleError()
+			return
+		}
+		zipName += ".zip"
 		outputZip := filepath.Join(p.exportDir, id.String(), zipName)
 		if err := archiver.NewZip().Archive(files, outputZip); err != nil {
 			opts.log.Printf("zip file failed: %v", err)
@@ -213,11 +241,6 @@ func Process(path string, fd FormData, month string, year int, opts *Options) er
 		return errors.Wrap(err, "failed to create output directory")
 	}
 
-	template, err := bundled.Asset("assets/vat-template.xlsx")
-	if err != nil {
-		return errors.Wrap(err, "failed to find VAT form template")
-	}
-
 	// set up temporary database
 	db, tempdir, err := createTempDB()
 	if err != nil {
@@ -245,15 +268,11 @@ func Process(path string, fd FormData, month string, year int, opts *Options) er
 		}
 
 		lowerP := strings.ToLower(path)
-		if !(strings.HasSuffix(lowerP, "jpg") || strings.HasSuffix(lowerP, "png") || strings.HasSuffix(lowerP, "pdf")) {
+		if !(isImage(lowerP) || strings.HasSuffix(lowerP, "pdf") || strings.HasSuffix(lowerP, "xml") || isMail(lowerP)) {
 			return nil
 		}
-
-		// best effort at exif rotate
-		if err := img.ExifRotate(path); err != nil {
-			opts.log.Printf("failed to exif rotate: %s; skipping", err)
-		}
-
+		// images are turned upright from their EXIF orientation when they are decoded, the original files are
+		// never changed
 		tasks = append(tasks, task{path})
 
 		return nil
@@ -283,54 +302,48 @@ func Process(path string, fd FormData, month string, year int, opts *Options) er
 
 	}
 
+	// the bar moves once per file because a PDF, mbox or e-invoice can have several receipts
+	step := func() {}
+	if opts.Interactive {
+		step = it.Increment
+	}
+	progress := newTaskProgress(step)
+
 	// these numbers dont matter, this is only a temp database
 	accountID, batchID := "1", "1"
 
-	errorWriter := svc.WriteErrors(filepath.Join(opts.OutputPath, "errors.txt"))
+	errorWriter := svc.WriteErrors(filepath.Join(opts.OutputPath, svc.ReviewFile))
 	proc := svc.NewParallelProcessor(db, accountID, batchID, &svc.ParallelOptions{
 		ReprocessOnRulesChange: true,
 		NumProcs:               20,
 		Hooks: &svc.Hooks{
 			AfterEach: func(r *svc.Receipt) error {
-				if opts.Interactive {
-					it.Increment()
-				}
+				progress.finished(r.Filename)
 				if err := errorWriter(r); err != nil {
 					return err
 				}
+				if len(r.Warnings) > 0 {
+					opts.mu.Lock()
+					for _, w := range r.Warnings {
+						opts.log.Printf("%s: %s", r.Filename, w)
+						opts.warnings = append(opts.warnings, fmt.Sprintf("%s: %s", filepath.Base(r.Filename), w))
+					}
+					opts.mu.Unlock()
+				}
 				return nil
 			},
 		},
-		KeyPath: opts.CredentialPath,
+		KeyPath:    opts.CredentialPath,
+		Preprocess: opts.Preprocess,
+		Quality:    opts.Quality,
 	})
 
 	start := time.Now()
 	for _, task := range tasks {
-		f, err := os.Open(task.path)
-		if err != nil {
-			continue
-		}
-
-		// convert PDF to image if not already done
-		var image img.Image
-		if strings.HasSuffix(task.path, "pdf") {
-			r, err := pdf.PdfToImage(f)
-			if err != nil {
-				continue
-			}
-			image, err = img.NewImageFromReader(r)
-			if err != nil {
-				continue
-			}
-		} else {
-			image, err = img.NewImageFromReader(f)
-			if err != nil {
-				continue
-			}
-		}
-		if err := proc.Add(task.path, image); err != nil {
-			return errors.Wrapf(err, "failed when processing %s", task.path)
+		if err := addTask(progress.start(proc, task.path), task.path, opts); err != nil {
+			return err
 		}
+		progress.done(task.path)
 	}
 	if err := proc.Wait(); err != nil {
 		if opts.Interactive {
@@ -353,18 +366,22 @@ func Process(path string, fd FormData, month string, year int, opts *Options) er
 	opts.log.Printf("filling forms with data: %+v", fd)
 	export := svc.NewExportService(db)
 	if err := export.Create(accountID, batchID, &svc.ExportOptions{
-		FirstName:    fd.FirstName,
-		LastName:     fd.LastName,
-		FullName:     fd.FullName,
-		DiplomaticID: fd.DiplomaticID,
-		Embassy:      fd.Embassy,
-		Month:        month,
-		MonthInt:     monthInt,
-		Year:         year,
-		Stamp:        []string{fd.FullName, fd.Embassy, fd.Address},
-		Bank:         fd.Bank,
-		Template:     template,
-		OutputDir:    opts.OutputPath,
+		FirstName:      fd.FirstName,
+		LastName:       fd.LastName,
+		FullName:       fd.FullName,
+		DiplomaticID:   fd.DiplomaticID,
+		Embassy:        fd.Embassy,
+		Country:        fd.Country,
+		FileNames:      fd.FileNames,
+		Month:          month,
+		MonthInt:       monthInt,
+		Year:           year,
+		Stamp:          []string{fd.FullName, fd.Embassy, fd.Address},
+		Bank:           fd.Bank,
+		OutputDir:      opts.OutputPath,
+		TextLayer:      true,
+		ConvertXLS2PDF: opts.PDFForms,
+		DataFormats:    opts.DataFormats,
 	}); err != nil {
 		if opts.Interactive {
 			exp.Fail()
@@ -382,6 +399,252 @@ func Process(path string, fd FormData, month string, year int, opts *Options) er
 	return nil
 }
 
+// addTask queues the receipts in one file.  Files that can't be read are logged and skipped, only errors from the
+// processor stop the batch.
+func addTask(proc svc.Processor, path string, opts *Options) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	lower := strings.ToLower(path)
+
+	switch {
+	// e-invoices skip OCR and are saved directly
+	case strings.HasSuffix(lower, "xml"):
+		if err := importEInvoice(proc, path, f); err != nil {
+			opts.log.Printf("failed to import e-invoice %s: %s", path, err)
+		}
+		return nil
+
+	// receipts attached to emails are queued with the sender and subject
+	case isMail(lower):
+		if err := importMail(proc, path, f); err != nil {
+			opts.log.Printf("failed to import email %s: %s", path, err)
+		}
+		return nil
+
+	// convert PDF to images if not already done, which can be one receipt per page
+	case strings.HasSuffix(lower, "pdf"):
+		images, err := pdfImages(f)
+		if err != nil {
+			opts.log.Printf("failed to convert pdf %s: %s", path, err)
+			return nil
+		}
+		for i, image := range images {
+			name := path
+			if len(images) > 1 {
+				name = fmt.Sprintf("%s#%d", path, i+1)
+			}
+			if err := proc.Add(name, image); err != nil {
+				return errors.Wrapf(err, "failed when processing %s", name)
+			}
+		}
+		return nil
+	}
+
+	image, err := img.NewImageFromReader(f)
+	if err != nil {
+		return nil
+	}
+	if err := proc.Add(path, image); err != nil {
+		return errors.Wrapf(err, "failed when processing %s", path)
+	}
+	return nil
+}
+
+// taskProgress moves the progress bar once for each file when all of the receipts queued from it are done.  Each
+// file counts one extra receipt while it is being queued so that it can't finish before everything is added.
+type taskProgress struct {
+	mu        sync.Mutex
+	step      func()
+	remaining map[string]int
+	tasks     map[string]string
+}
+
+func newTaskProgress(step func()) *taskProgress {
+	return &taskProgress{step: step, remaining: make(map[string]int), tasks: make(map[string]string)}
+}
+
+// start returns a processor that counts the receipts queued for the file at path
+func (t *taskProgress) start(proc svc.Processor, path string) svc.Processor {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining[path]++
+	return taskProcessor{Processor: proc, progress: t, task: path}
+}
+
+func (t *taskProgress) add(task string, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining[task]++
+	t.tasks[name] = task
+}
+
+// finished is called after each receipt is saved
+func (t *taskProgress) finished(name string) {
+	t.mu.Lock()
+	task, ok := t.tasks[name]
+	delete(t.tasks, name)
+	t.mu.Unlock()
+	if ok {
+		t.done(task)
+	}
+}
+
+// done is called when a file has queued all of its receipts
+func (t *taskProgress) done(task string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining[task]--
+	if t.remaining[task] > 0 {
+		return
+	}
+	delete(t.remaining, task)
+	t.step()
+}
+
+type taskProcessor struct {
+	svc.Processor
+	progress *taskProgress
+	task     string
+}
+
+func (p taskProcessor) Add(name string, image img.Image) error {
+	return p.AddFrom(name, image, nil)
+}
+
+func (p taskProcessor) AddFrom(name string, image img.Image, source *svc.Provenance) error {
+	p.progress.add(p.task, name)
+	return p.Processor.AddFrom(name, image, source)
+}
+
+func (p taskProcessor) Import(name string, receipt *svc.Receipt, image img.Image) error {
+	p.progress.add(p.task, name)
+	if err := p.Processor.Import(name, receipt, image); err != nil {
+		// the receipt wasn't saved so it won't be finished by the hook
+		p.progress.finished(name)
+		return err
+	}
+	return nil
+}
+
+// importEInvoice parses every invoice in an e-invoice file and adds it to the batch along with a rendered page
+// image for the invoice packet
+func importEInvoice(proc svc.Processor, path string, r io.Reader) error {
+	invoices, err := einvoice.Parse(r)
+	if err != nil {
+		return err
+	}
+	for i, inv := range invoices {
+		image, err := svc.RenderInvoice(inv)
+		if err != nil {
+			return errors.Wrap(err, "failed to render e-invoice")
+		}
+		name := path
+		if len(invoices) > 1 {
+			name = fmt.Sprintf("%s#%d", path, i+1)
+		}
+		if err := proc.Import(name, svc.NewReceiptFromInvoice(inv), image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pdfImages converts a pdf to one image per receipt
+func pdfImages(r io.ReadCloser) ([]img.Image, error) {
+	pages, err := pdf.PdfToImages(r, pdf.PagesAuto)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, p := range pages {
+			p.Close()
+		}
+	}()
+
+	var images []img.Image
+	for _, p := range pages {
+		image, err := img.NewImageFromReader(p)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// isImage is true for image formats that img can decode
+func isImage(path string) bool {
+	for _, ext := range []string{"jpg", "jpeg", "png", "heic", "heif", "webp", "tif", "tiff"} {
+		if strings.HasSuffix(path, "."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMail(path string) bool {
+	return strings.HasSuffix(path, "eml") || strings.HasSuffix(path, "mbox")
+}
+
+// importMail adds every PDF and image attachment in an .eml or mbox file to the batch.  Messages without
+// attachments have their HTML body rendered as a page instead.
+func importMail(proc svc.Processor, path string, r io.Reader) error {
+	var messages []inbox.Message
+	if strings.HasSuffix(strings.ToLower(path), "mbox") {
+		var err error
+		messages, err = inbox.ReadMbox(r)
+		if err != nil {
+			return err
+		}
+	} else {
+		msg, err := inbox.ReadMessage(r)
+		if err != nil {
+			return err
+		}
+		messages = []inbox.Message{msg}
+	}
+
+	for i, msg := range messages {
+		source := &svc.Provenance{From: msg.From, Subject: msg.Subject}
+		for _, a := range msg.Attachments {
+			var image img.Image
+			var images []img.Image
+			var err error
+			switch a.Kind {
+			case inbox.PDF:
+				images, err = pdfImages(ioutil.NopCloser(bytes.NewReader(a.Data)))
+			case inbox.HTML:
+				image, err = img.RenderText(inbox.Text(a.Data))
+				images = []img.Image{image}
+			default:
+				image, err = img.NewImageFromReader(bytes.NewReader(a.Data))
+				images = []img.Image{image}
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s from message %q", a.Name, msg.Subject)
+			}
+
+			name := fmt.Sprintf("%s#%s", path, a.Name)
+			if len(messages) > 1 {
+				name = fmt.Sprintf("%s#%d#%s", path, i+1, a.Name)
+			}
+			for j, image := range images {
+				pageName := name
+				if len(images) > 1 {
+					pageName = fmt.Sprintf("%s#%d", name, j+1)
+				}
+				if err := proc.AddFrom(pageName, image, source); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
 func DefaultOptions(path string) *Options {
 	// default options are set for CLI ops
 	return &Options{
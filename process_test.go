@@ -0,0 +1,65 @@
+package vatinator
+
+import (
+	"testing"
+
+	"github.com/BTBurke/vatinator/img"
+	"github.com/BTBurke/vatinator/svc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueProcessor remembers the receipts it was given instead of processing them
+type queueProcessor struct {
+	names []string
+}
+
+func (q *queueProcessor) Add(name string, image img.Image) error {
+	return q.AddFrom(name, image, nil)
+}
+
+func (q *queueProcessor) AddFrom(name string, image img.Image, source *svc.Provenance) error {
+	q.names = append(q.names, name)
+	return nil
+}
+
+func (q *queueProcessor) Import(name string, receipt *svc.Receipt, image img.Image) error {
+	return q.AddFrom(name, image, nil)
+}
+
+func (q *queueProcessor) Wait() error { return nil }
+
+func TestTaskProgress(t *testing.T) {
+	var steps int
+	progress := newTaskProgress(func() { steps++ })
+	q := &queueProcessor{}
+	var page img.Image
+
+	// a PDF split into three receipts moves the bar once, after the last one is done
+	proc := progress.start(q, "statement.pdf")
+	for _, name := range []string{"statement.pdf#1", "statement.pdf#2", "statement.pdf#3"} {
+		require.NoError(t, proc.Add(name, page))
+	}
+	progress.finished("statement.pdf#1")
+	progress.done("statement.pdf")
+	assert.Equal(t, 0, steps)
+	progress.finished("statement.pdf#2")
+	progress.finished("statement.pdf#3")
+	assert.Equal(t, 1, steps)
+
+	// a file that can't be read still counts
+	progress.start(q, "broken.pdf")
+	progress.done("broken.pdf")
+	assert.Equal(t, 2, steps)
+
+	// receipts that finish while the file is still being queued don't finish the file
+	proc = progress.start(q, "mail.mbox")
+	require.NoError(t, proc.Add("mail.mbox#1#a.pdf", page))
+	progress.finished("mail.mbox#1#a.pdf")
+	assert.Equal(t, 2, steps)
+	require.NoError(t, proc.Add("mail.mbox#2#b.pdf", page))
+	progress.done("mail.mbox")
+	progress.finished("mail.mbox#2#b.pdf")
+	assert.Equal(t, 3, steps)
+	assert.Len(t, q.names, 5)
+}
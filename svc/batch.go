@@ -14,7 +14,17 @@ type Batch struct {
 	NumReceipts int
 	VAT         int
 	Total       int
-	Closed      int64
+	// Precision of VAT and Total, which is 3 digits when any receipt in the batch has 3 digits
+	Precision Precision
+	Closed    int64
+}
+
+func (b *Batch) GetTotal() string {
+	return formatCurrency(b.Total, b.Precision)
+}
+
+func (b *Batch) GetVAT() string {
+	return formatCurrency(b.VAT, b.Precision)
 }
 
 func (b *Batch) MarshalBinary() ([]byte, error) {
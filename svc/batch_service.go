@@ -63,16 +63,7 @@ func getBatch(txn *badger.Txn, key *BatchKey, batch *Batch, persistView bool) ([
 		return nil, err
 	}
 
-	batch.NumReceipts = len(receipts)
-
-	total := 0
-	vat := 0
-	for _, r := range receipts {
-		total += r.Total
-		vat += r.VAT
-	}
-	batch.VAT = vat
-	batch.Total = total
+	batchTotals(batch, receipts)
 
 	if persistView {
 		if err := db.Set(txn, key, batch); err != nil {
@@ -82,6 +73,37 @@ func getBatch(txn *badger.Txn, key *BatchKey, batch *Batch, persistView bool) ([
 	return receipts, nil
 }
 
+// batchTotals counts the receipts and adds up the ones that go on the forms.  Card slips and other documents are left
+// off the forms, so they don't count towards the totals.
+func batchTotals(batch *Batch, receipts []Receipt) {
+	batch.NumReceipts = len(receipts)
+
+	var claimable []Receipt
+	for _, r := range receipts {
+		if r.IsClaimable() {
+			claimable = append(claimable, r)
+		}
+	}
+
+	// totals are kept at 3 digits if any receipt uses them so that no precision is lost
+	precision := Digit2
+	for _, r := range claimable {
+		if r.CurrencyPrecision == Digit3 {
+			precision = Digit3
+		}
+	}
+
+	total := 0
+	vat := 0
+	for _, r := range claimable {
+		total += r.TotalAs(precision)
+		vat += r.VATAs(precision)
+	}
+	batch.VAT = vat
+	batch.Total = total
+	batch.Precision = precision
+}
+
 func (b b) CloseBatch(accountID string, batchID string) error {
 	key := &BatchKey{accountID, batchID}
 	batch := &Batch{}
@@ -0,0 +1,22 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchTotals(t *testing.T) {
+	receipts := []Receipt{
+		{Total: 1200, VAT: 200, CurrencyPrecision: Digit2, DocumentType: "fiscal receipt"},
+		{Total: 600, VAT: 100, CurrencyPrecision: Digit2},
+		{Total: 1200, VAT: 200, CurrencyPrecision: Digit2, DocumentType: "card slip"},
+		{Total: 1000, VAT: 167, CurrencyPrecision: Digit3, Rejected: true},
+	}
+	batch := &Batch{}
+	batchTotals(batch, receipts)
+	assert.Equal(t, 4, batch.NumReceipts)
+	// the card slip and the rejected photo aren't on the forms, so the totals don't switch to 3 digits either
+	assert.Equal(t, "18.00", batch.GetTotal())
+	assert.Equal(t, "3.00", batch.GetVAT())
+}
@@ -0,0 +1,168 @@
+package svc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/vatinator/types"
+	"github.com/pkg/errors"
+)
+
+// DataFormat is a machine readable export of the receipts in a batch, for importing into a bookkeeping spreadsheet
+type DataFormat string
+
+const (
+	CSV  DataFormat = "csv"
+	JSON DataFormat = "json"
+)
+
+// ParseDataFormats reads a list of data formats like csv,json
+func ParseDataFormats(s string) ([]DataFormat, error) {
+	var out []DataFormat
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		switch DataFormat(f) {
+		case "":
+		case CSV, JSON:
+			out = append(out, DataFormat(f))
+		default:
+			return nil, fmt.Errorf("unknown data format %q, use csv or json", f)
+		}
+	}
+	return out, nil
+}
+
+// ReceiptData is a receipt with where it is on the forms.  Amounts are also given with a decimal point.  Packets
+// and lines are 0 when the receipt isn't on that form.
+type ReceiptData struct {
+	Receipt
+
+	TotalAmount  string
+	VATAmount    string
+	ExciseTax    string `json:",omitempty"`
+	VATPacket    int
+	VATLine      int
+	ExcisePacket int
+	ExciseLine   int
+	// Excluded is why the receipt was left off the forms
+	Excluded string `json:",omitempty"`
+}
+
+// receiptData lists the receipts on the forms in form order, followed by the ones left off
+func receiptData(receipts []Receipt, excluded []Receipt, vatLines int) []ReceiptData {
+	var out []ReceiptData
+	for i, p := range placements(receipts, vatLines) {
+		d := newReceiptData(receipts[i])
+		d.VATPacket, d.VATLine = p.VATPacket, p.VATLine
+		d.ExcisePacket, d.ExciseLine = p.ExcisePacket, p.ExciseLine
+		out = append(out, d)
+	}
+	for _, r := range excluded {
+		d := newReceiptData(r)
+		d.Excluded = excludedReason(r)
+		out = append(out, d)
+	}
+	return out
+}
+
+func newReceiptData(r Receipt) ReceiptData {
+	d := ReceiptData{
+		Receipt:     r,
+		TotalAmount: r.GetTotal(),
+		VATAmount:   r.GetVAT(),
+	}
+	if r.IsExcise {
+		d.ExciseTax = types.Currency(exciseFor(r).TaxAmount()).String()
+	}
+	return d
+}
+
+// writeData writes every receipt in the batch in each of the data formats
+func writeData(receipts []Receipt, excluded []Receipt, vatLines int, batchID string, opts *ExportOptions) error {
+	data := receiptData(receipts, excluded, vatLines)
+	for _, format := range opts.DataFormats {
+		path, err := opts.path("", "Receipts", 0, batchID, "."+string(format))
+		if err != nil {
+			return err
+		}
+		f, err := os.Create(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create receipt data %s", path)
+		}
+		switch format {
+		case CSV:
+			err = writeCSV(f, data)
+		case JSON:
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(data)
+		default:
+			err = fmt.Errorf("unknown data format %q", format)
+		}
+		if err != nil {
+			f.Close()
+			return errors.Wrapf(err, "failed to write receipt data %s", path)
+		}
+		if err := f.Close(); err != nil {
+			return errors.Wrapf(err, "failed to write receipt data %s", path)
+		}
+	}
+	return nil
+}
+
+var csvHeader = []string{
+	"ID", "Filename", "Vendor", "TaxID", "Date", "ReceiptNumber", "Total", "VAT", "CurrencyPrecision",
+	"TaxSubtotals", "DocumentType", "IsExcise", "ExciseType", "ExciseAmount", "ExciseTax",
+	"VATPacket", "VATLine", "ExcisePacket", "ExciseLine", "Excluded", "Rejected", "Errors", "Warnings",
+	"BatchID", "Reviewed", "RulesVersion", "EmailFrom", "EmailSubject",
+}
+
+// writeCSV writes one row for each receipt.  Amounts have a decimal point so they can be summed in a spreadsheet.
+// The OCR words and stamp position are only in the JSON.
+func writeCSV(out io.Writer, data []ReceiptData) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(csvHeader); err != nil {
+		return err
+	}
+	number := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	for _, d := range data {
+		var subtotals []string
+		for _, s := range d.TaxSubtotals {
+			subtotals = append(subtotals, fmt.Sprintf("%s%%: %s VAT %s", s.Rate, formatCurrency(s.Taxable, d.CurrencyPrecision), formatCurrency(s.VAT, d.CurrencyPrecision)))
+		}
+		precision := d.CurrencyPrecision
+		if precision == 0 {
+			precision = Digit2
+		}
+		reviewed := ""
+		if d.Reviewed > 0 {
+			reviewed = time.Unix(d.Reviewed, 0).UTC().Format(time.RFC3339)
+		}
+		var from, subject string
+		if d.Provenance != nil {
+			from, subject = d.Provenance.From, d.Provenance.Subject
+		}
+		if err := w.Write([]string{
+			d.ID, d.Filename, d.Vendor, d.TaxID, d.Date, d.ReceiptNumber, d.TotalAmount, d.VATAmount, strconv.Itoa(int(precision)),
+			strings.Join(subtotals, "; "), d.DocumentType, strconv.FormatBool(d.IsExcise), d.ExciseType, d.ExciseAmount, d.ExciseTax,
+			number(d.VATPacket), number(d.VATLine), number(d.ExcisePacket), number(d.ExciseLine), d.Excluded, strconv.FormatBool(d.Rejected),
+			strings.Join(d.Errors, "; "), strings.Join(d.Warnings, "; "),
+			d.BatchID, reviewed, d.RulesVersion, from, subject,
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,85 @@
+package svc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDataFormats(t *testing.T) {
+	tt := []struct {
+		in   string
+		want []DataFormat
+		err  bool
+	}{
+		{"", nil, false},
+		{"csv", []DataFormat{CSV}, false},
+		{"CSV, json", []DataFormat{CSV, JSON}, false},
+		{"xlsx", nil, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseDataFormats(tc.in)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "data")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	receipts := []Receipt{
+		{ID: "r1", Filename: "rimi.jpg", Vendor: "Rimi", Date: "02/01/2021", Total: 1200, VAT: 200, CurrencyPrecision: Digit2,
+			TaxSubtotals: []TaxSubtotal{{Rate: "20", Taxable: 1000, VAT: 200}}},
+		{ID: "r2", Filename: "alexela.jpg", Vendor: "Alexela", Date: "03/01/2021", Total: 30555, VAT: 5093, CurrencyPrecision: Digit3,
+			IsExcise: true, ExciseType: "Gas 95", ExciseAmount: "20.00", Provenance: &Provenance{From: "mari@example.com"}},
+	}
+	excluded := []Receipt{{ID: "r3", Filename: "slip.jpg", DocumentType: "card slip"}}
+	opts := &ExportOptions{LastName: "Maasikas", Month: "JANUARY", Year: 2021, OutputDir: dir, DataFormats: []DataFormat{CSV, JSON}}
+	require.NoError(t, writeData(receipts, excluded, 1, "b1", opts))
+
+	f, err := os.Open(filepath.Join(dir, "USA-Maasikas-JANUARY2021-Receipts.csv"))
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	col := make(map[string]int)
+	for i, h := range rows[0] {
+		col[h] = i
+	}
+	assert.Equal(t, "rimi.jpg", rows[1][col["Filename"]])
+	assert.Equal(t, "12.00", rows[1][col["Total"]])
+	assert.Equal(t, "20%: 10.00 VAT 2.00", rows[1][col["TaxSubtotals"]])
+	assert.Equal(t, "30.555", rows[2][col["Total"]])
+	assert.Equal(t, "2", rows[2][col["VATPacket"]])
+	assert.Equal(t, "1", rows[2][col["ExciseLine"]])
+	assert.Equal(t, "11.26", rows[2][col["ExciseTax"]])
+	assert.Equal(t, "mari@example.com", rows[2][col["EmailFrom"]])
+	assert.Equal(t, "", rows[3][col["VATPacket"]])
+	assert.Contains(t, rows[3][col["Excluded"]], "card slip")
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "USA-Maasikas-JANUARY2021-Receipts.json"))
+	require.NoError(t, err)
+	var data []ReceiptData
+	require.NoError(t, json.Unmarshal(b, &data))
+	require.Len(t, data, 3)
+	assert.Equal(t, receipts[1], data[1].Receipt)
+	assert.Equal(t, 2, data[1].VATPacket)
+	assert.Equal(t, "30.555", data[1].TotalAmount)
+	assert.Equal(t, "11.26", data[1].ExciseTax)
+	assert.Equal(t, excluded[0], data[2].Receipt)
+}
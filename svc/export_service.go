@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,21 +24,39 @@ type ExportService interface {
 }
 
 type ExportOptions struct {
-	FirstName         string
-	LastName          string
-	FullName          string
-	Bank              string
-	DiplomaticID      string
-	Month             string
-	MonthInt          int
-	Year              int
-	Embassy           string
-	Stamp             []string
-	OutputDir         string
-	ConvertXLS2PDF    bool
+	FirstName    string
+	LastName     string
+	FullName     string
+	Bank         string
+	DiplomaticID string
+	Month        string
+	MonthInt     int
+	Year         int
+	Embassy      string
+	// Country is the code of the mission country used in file names (default: USA)
+	Country string
+	// FileNames is the template for the names of the exported files (default: DefaultFileNames)
+	FileNames string
+	Stamp     []string
+	// StampOptions set the stamp color, size and opacity (default: img.DefaultStampOptions)
+	StampOptions *img.StampOptions
+	// PageSize of the invoice packets (default: A4)
+	PageSize pdf.PageSize
+	// OnePerPage puts each receipt on its own page instead of tiling short receipts
+	OnePerPage bool
+	// TextLayer adds the OCR text to the invoice packets as invisible text so they can be searched
+	TextLayer bool
+	OutputDir string
+	// ConvertXLS2PDF also writes each VAT form as a PDF next to the xlsx
+	ConvertXLS2PDF bool
+	// FillExciseOptions can replace the blank excise form
 	FillExciseOptions *pdf.FillExciseOptions
-	// template for the VAT form in XLS
+	// SubmissionDate picks the version of the VAT form template (default: today)
+	SubmissionDate time.Time
+	// Template replaces the VAT form in XLS of the chosen version
 	Template []byte
+	// DataFormats also writes every receipt in the batch as CSV or JSON for bookkeeping
+	DataFormats []DataFormat
 }
 
 func DefaultExportOptions() *ExportOptions {
@@ -69,6 +88,9 @@ func create(txn *badger.Txn, accountID string, batchID string, opts *ExportOptio
 	if opts == nil {
 		opts = DefaultExportOptions()
 	}
+	if err := ValidateFileNames(opts.FileNames); err != nil {
+		return err
+	}
 
 	if _, err := os.Stat(opts.OutputDir); os.IsNotExist(err) {
 		if err := os.Mkdir(opts.OutputDir, 0755); err != nil {
@@ -90,10 +112,28 @@ func create(txn *badger.Txn, accountID string, batchID string, opts *ExportOptio
 		return stringToDate(receipts[i].Date).UTC().Before(stringToDate(receipts[j].Date))
 	})
 
-	if err := writeInvoices(txn, accountID, receipts, vat, opts); err != nil {
+	// card slips and other documents are reported for review instead of going on the forms
+	var claimable, excluded []Receipt
+	for _, r := range receipts {
+		if r.IsClaimable() {
+			claimable = append(claimable, r)
+		} else {
+			excluded = append(excluded, r)
+		}
+	}
+	receipts = claimable
+	if err := WriteReview(filepath.Join(opts.OutputDir, ReviewFile), receipts, excluded); err != nil {
+		return errors.Wrap(err, "failed to write review file")
+	}
+
+	layout, err := vatLayout(opts)
+	if err != nil {
 		return err
 	}
-	if err := writeVATForm(receipts, opts); err != nil {
+	if err := writeInvoices(txn, accountID, batchID, receipts, vat, layout.MaxLines, opts); err != nil {
+		return err
+	}
+	if err := writeVATForm(receipts, layout, batchID, opts); err != nil {
 		return err
 	}
 
@@ -103,24 +143,25 @@ func create(txn *badger.Txn, accountID string, batchID string, opts *ExportOptio
 	for _, r := range receipts {
 		if r.IsExcise {
 			exciseReceipts = append(exciseReceipts, r)
-			excises = append(excises, types.Excise{
-				Type:    r.ExciseType,
-				Amount:  r.ExciseAmount,
-				Arve:    r.ReceiptNumber,
-				Content: "", // empty string for gas receipts
-				Date:    r.Date,
-			})
+			excises = append(excises, exciseFor(r))
 		}
 	}
 	if len(exciseReceipts) > 0 {
-		if err := writeInvoices(txn, accountID, exciseReceipts, excise, opts); err != nil {
+		if err := writeInvoices(txn, accountID, batchID, exciseReceipts, excise, pdf.MaxExciseLines, opts); err != nil {
 			return err
 		}
-		if err := writeExciseForm(excises, opts); err != nil {
+		if err := writeExciseForm(excises, batchID, opts); err != nil {
 			return err
 		}
 	}
 
+	if err := writeSummary(txn, accountID, batchID, receipts, excluded, layout.MaxLines, opts); err != nil {
+		return err
+	}
+	if err := writeData(receipts, excluded, layout.MaxLines, batchID, opts); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -131,47 +172,74 @@ const (
 	excise invoiceType = "Excise"
 )
 
-func writeInvoices(txn *badger.Txn, accountID string, receipts []Receipt, t invoiceType, opts *ExportOptions) error {
-	var perPacket int
+// title describes the invoice packet in the PDF metadata
+func (t invoiceType) title() string {
 	switch t {
-	case vat:
-		perPacket = 17
 	case excise:
-		perPacket = 6
+		return "Excise fuel invoices"
 	default:
+		return "VAT invoices"
+	}
+}
+
+// writeInvoices writes the receipts in packets of perPacket, which is the number of lines on the matching form
+func writeInvoices(txn *badger.Txn, accountID string, batchID string, receipts []Receipt, t invoiceType, perPacket int, opts *ExportOptions) error {
+	if t != vat && t != excise {
 		return fmt.Errorf("unknown invoice type %s", t)
 	}
+	// page N is row N of the excise form
+	onePerPage := opts.OnePerPage || t == excise
 
-	packets := len(receipts)/perPacket + 1
-	for packet := 0; packet < packets; packet++ {
+	for packet, bounds := range packets(len(receipts), perPacket) {
 
 		var fpath string
+		var err error
 		switch t {
 		case vat:
-			fpath = filepath.Join(opts.OutputDir, fmt.Sprintf("USA-%s-VAT-%s%d-Invoices%d.pdf", opts.LastName, opts.Month, opts.Year, packet+1))
+			fpath, err = opts.path("VAT", "Invoices", packet+1, batchID, ".pdf")
 		case excise:
-			fpath = filepath.Join(opts.OutputDir, fmt.Sprintf("USA-%s-Excise-%s%d-Fuel_Invoices%d.pdf", opts.LastName, opts.Month, opts.Year, packet+1))
+			fpath, err = opts.path("Excise", "Fuel_Invoices", packet+1, batchID, ".pdf")
 		}
-		p := pdf.NewPDF(fpath)
+		if err != nil {
+			return err
+		}
+		p := pdf.NewPDFWithOptions(fpath, &pdf.Options{
+			PageSize:   opts.PageSize,
+			Margin:     pdf.DefaultOptions.Margin,
+			OnePerPage: onePerPage,
+			Batch:      batchID,
+			Packet:     packet + 1,
+			Title:      fmt.Sprintf("%s %s %d packet %d", t.title(), opts.Month, opts.Year, packet+1),
+			Author:     opts.FullName,
+		})
 
 		// Write each receipt to as page in PDF
-		for i := 0; i < perPacket; i++ {
-			current := packet*perPacket + i
-			if current >= len(receipts) {
-				continue
-			}
+		for current := bounds[0]; current < bounds[1]; current++ {
+			i := current - bounds[0]
 			id := receipts[current].ID
 			image, err := getImage(txn, accountID, id)
 			if err != nil {
 				return errors.Wrap(err, "failed to get image")
 			}
 
-			composited, err := img.CompositeReceipt(i+1, image, opts.Stamp, 0)
+			r := &receipts[current]
+			composited, placement, err := img.CompositeReceiptWithOptions(i+1, image, opts.Stamp, opts.StampOptions, img.Boxes(r.Words), r.Stamp)
 			if err != nil {
 				return errors.Wrap(err, "failed to composite image")
 			}
+			// keep the stamp where it is on the next export
+			if r.Stamp == nil && placement != nil {
+				r.Stamp = placement
+				if err := upsertReceipt(txn, accountID, r); err != nil {
+					return errors.Wrap(err, "failed to save stamp position")
+				}
+			}
 
-			if err := p.WriteReceipt(composited); err != nil {
+			var words []img.Word
+			if opts.TextLayer {
+				words = img.CompositeWords(image, r.Words)
+			}
+			if err := p.WriteReceiptWithText(composited, words); err != nil {
 				return errors.Wrap(err, "failed to write receipt to pdf")
 			}
 		}
@@ -183,68 +251,165 @@ func writeInvoices(txn *badger.Txn, accountID string, receipts []Receipt, t invo
 	return nil
 }
 
-func writeExciseForm(receipts []types.Excise, opts *ExportOptions) error {
-	packets := len(receipts)/6 + 1
-	for packet := 0; packet < packets; packet++ {
-		excisePath := filepath.Join(opts.OutputDir, fmt.Sprintf("USA-%s-Excise-%s%d-Fuel_Form%d.pdf", opts.LastName, opts.Month, opts.Year, packet+1))
-		if err := pdf.FillExcise(excisePath, receipts, types.ExciseMetadata{
+// writeExciseForm fills one excise form for each packet of fuel receipts.  Rows are in the same order as the
+// pages of the matching Fuel_Invoices packet and the total is for that form only.
+func writeExciseForm(receipts []types.Excise, batchID string, opts *ExportOptions) error {
+	for packet, bounds := range packets(len(receipts), pdf.MaxExciseLines) {
+		excisePath, err := opts.path("Excise", "Fuel_Form", packet+1, batchID, ".pdf")
+		if err != nil {
+			return err
+		}
+		if err := pdf.FillExcise(excisePath, receipts[bounds[0]:bounds[1]], types.ExciseMetadata{
 			Bank:    opts.Bank,
 			Name:    opts.FullName,
 			Embassy: opts.Embassy,
 			Date:    fmt.Sprintf("%s %d", opts.Month, opts.Year),
-		}, nil); err != nil {
+		}, opts.FillExciseOptions); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func writeVATForm(receipts []Receipt, opts *ExportOptions) error {
-	packets := len(receipts)/17 + 1
-	for packet := 0; packet < packets; packet++ {
+// vatLayout picks the VAT form template version for the submission date
+func vatLayout(opts *ExportOptions) (xls.Layout, error) {
+	layouts, err := xls.Layouts()
+	if err != nil {
+		return xls.Layout{}, err
+	}
+	submitted := opts.SubmissionDate
+	if submitted.IsZero() {
+		submitted = time.Now()
+	}
+	return xls.LayoutFor(layouts, submitted)
+}
 
-		xpath := filepath.Join(opts.OutputDir, fmt.Sprintf("USA-%s-VAT-%s%d-VAT%d.xlsx", opts.LastName, opts.Month, opts.Year, packet+1))
-		xlsfile, err := xls.NewFromTemplate(xpath, opts.Template)
+func writeVATForm(receipts []Receipt, layout xls.Layout, batchID string, opts *ExportOptions) error {
+	template := opts.Template
+	if len(template) == 0 {
+		var err error
+		if template, err = layout.Blank(); err != nil {
+			return errors.Wrapf(err, "failed to find VAT form template %s", layout.Version)
+		}
+	}
+
+	for packet, bounds := range packets(len(receipts), layout.MaxLines) {
+
+		xpath, err := opts.path("VAT", "VAT", packet+1, batchID, ".xlsx")
+		if err != nil {
+			return err
+		}
+		xlsfile, err := xls.NewFromTemplate(xpath, template)
 		if err != nil {
 			return errors.Wrap(err, "failed to create new VAT file")
 		}
+		if err := layout.Check(xlsfile); err != nil {
+			return err
+		}
 
 		// write form header information
-		if err := xls.WriteName(opts.FullName, xlsfile); err != nil {
+		if err := layout.WriteName(opts.FullName, xlsfile); err != nil {
 			return err
 		}
-		if err := xls.WriteDipNumber(opts.DiplomaticID, xlsfile); err != nil {
+		if err := layout.WriteDipNumber(opts.DiplomaticID, xlsfile); err != nil {
 			return err
 		}
-		if err := xls.WriteSubmissionMonth(opts.MonthInt, opts.Year, xlsfile); err != nil {
+		if err := layout.WriteSubmissionMonth(opts.MonthInt, opts.Year, xlsfile); err != nil {
 			return err
 		}
-		if err := xls.WriteBankInfo(opts.Bank, xlsfile); err != nil {
+		if err := layout.WriteBankInfo(opts.Bank, xlsfile); err != nil {
 			return err
 		}
 
 		// Write each VAT line
-		for i := 0; i < 17; i++ {
-			current := packet*17 + i
-			if current >= len(receipts) {
-				continue
-			}
+		for current := bounds[0]; current < bounds[1]; current++ {
 			receipt := &receipts[current]
 
 			// write excel line
-			if err := xls.WriteVATLine(xlsfile, receipt, i); err != nil {
+			if err := layout.WriteVATLine(xlsfile, receipt, current-bounds[0]); err != nil {
 				return errors.Wrap(err, "failed to write line to VAT file")
 			}
 		}
 		if err := xlsfile.Save(xpath); err != nil {
 			return errors.Wrapf(err, "failed to save VAT file to %s", xpath)
 		}
+
+		// the same form as a PDF for people who can't open xlsx
+		if opts.ConvertXLS2PDF {
+			ppath := strings.TrimSuffix(xpath, ".xlsx") + ".pdf"
+			if err := pdf.WriteVATForm(ppath, vatForm(receipts[bounds[0]:bounds[1]], layout.MaxLines, opts)); err != nil {
+				return errors.Wrapf(err, "failed to save VAT form PDF to %s", ppath)
+			}
+		}
 	}
 
 	return nil
 
 }
 
+// vatForm fills the PDF version of the VAT form with one packet of receipts.  The table has the same number of
+// lines as the spreadsheet.
+func vatForm(receipts []Receipt, maxLines int, opts *ExportOptions) pdf.VATForm {
+	form := pdf.VATForm{
+		Name:         opts.FullName,
+		DiplomaticID: opts.DiplomaticID,
+		Bank:         opts.Bank,
+		Month:        opts.MonthInt,
+		Year:         opts.Year,
+		MaxLines:     maxLines,
+	}
+	for i := range receipts {
+		r := &receipts[i]
+		form.Lines = append(form.Lines, pdf.VATFormLine{
+			Vendor: r.GetVendor(),
+			Number: r.GetReceiptNumber(),
+			Date:   r.GetDate(),
+			Total:  r.GetTotal(),
+			VAT:    r.GetVAT(),
+		})
+	}
+	form.Total, form.VAT = totals(receipts)
+	return form
+}
+
+// packets splits n receipts into packets of up to perPacket and returns the start and end of each.  There are no
+// packets when there are no receipts.
+func packets(n int, perPacket int) [][2]int {
+	var out [][2]int
+	for start := 0; start < n; start += perPacket {
+		end := start + perPacket
+		if end > n {
+			end = n
+		}
+		out = append(out, [2]int{start, end})
+	}
+	return out
+}
+
+// path is where an exported file goes, named with the file name template.  Packet is 0 for files that cover the
+// whole batch.
+func (o *ExportOptions) path(tax string, kind string, packet int, batchID string, ext string) (string, error) {
+	n := ""
+	if packet > 0 {
+		n = strconv.Itoa(packet)
+	}
+	name, err := FileName{
+		Country: o.Country,
+		First:   o.FirstName,
+		Last:    o.LastName,
+		Tax:     tax,
+		Kind:    kind,
+		Month:   o.Month,
+		Year:    o.Year,
+		N:       n,
+		Batch:   batchID,
+	}.Format(o.FileNames)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(o.OutputDir, name+ext), nil
+}
+
 func stringToDate(d string) time.Time {
 	t, err := time.Parse("02/01/2006", d)
 	if err != nil {
@@ -0,0 +1,115 @@
+package svc
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTBurke/vatinator/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVATFormTotals(t *testing.T) {
+	receipts := []Receipt{
+		{Vendor: "Rimi", ReceiptNumber: "1", Date: "02/01/2021", Total: 1200, VAT: 200, CurrencyPrecision: Digit2},
+		{Vendor: "Alexela", ReceiptNumber: "2", Date: "03/01/2021", Total: 10555, VAT: 1759, CurrencyPrecision: Digit3},
+	}
+	form := vatForm(receipts, 20, &ExportOptions{FullName: "Mari Maasikas", MonthInt: 1, Year: 2021})
+	assert.Equal(t, "Mari Maasikas", form.Name)
+	assert.Equal(t, 20, form.MaxLines)
+	assert.Len(t, form.Lines, 2)
+	assert.Equal(t, "12.00", form.Lines[0].Total)
+	assert.Equal(t, "10.555", form.Lines[1].Total)
+	// 3 digits because one receipt has 3
+	assert.Equal(t, "22.555", form.Total)
+	assert.Equal(t, "3.759", form.VAT)
+}
+
+func TestPackets(t *testing.T) {
+	tt := []struct {
+		name      string
+		n         int
+		perPacket int
+		want      [][2]int
+	}{
+		{"none", 0, 17, nil},
+		{"one", 1, 17, [][2]int{{0, 1}}},
+		{"full", 17, 17, [][2]int{{0, 17}}},
+		{"one over", 18, 17, [][2]int{{0, 17}, {17, 18}}},
+		{"two full", 12, 6, [][2]int{{0, 6}, {6, 12}}},
+		{"excise", 7, 6, [][2]int{{0, 6}, {6, 7}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, packets(tc.n, tc.perPacket))
+		})
+	}
+}
+
+func TestExciseFormPackets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "excise-packets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var receipts []types.Excise
+	for i := 1; i <= 7; i++ {
+		receipts = append(receipts, types.Excise{Type: "Gas 95", Amount: "10", Tax: 100 * i, Arve: fmt.Sprintf("A%d", i), Date: "02/01/2021"})
+	}
+	opts := &ExportOptions{LastName: "Maasikas", Month: "JANUARY", Year: 2021, OutputDir: dir}
+	require.NoError(t, writeExciseForm(receipts, "b1", opts))
+
+	forms, err := filepath.Glob(filepath.Join(dir, "*Fuel_Form*.pdf"))
+	require.NoError(t, err)
+	assert.Len(t, forms, 2)
+
+	// the appearance streams are uncompressed, so the values drawn on each form can be checked directly
+	first, err := ioutil.ReadFile(filepath.Join(dir, "USA-Maasikas-Excise-JANUARY2021-Fuel_Form1.pdf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(first), "(A6 / 02/01/2021) Tj")
+	assert.NotContains(t, string(first), "(A7 / 02/01/2021) Tj")
+	assert.Contains(t, string(first), "(21.00) Tj")
+
+	second, err := ioutil.ReadFile(filepath.Join(dir, "USA-Maasikas-Excise-JANUARY2021-Fuel_Form2.pdf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(second), "(A7 / 02/01/2021) Tj")
+	assert.NotContains(t, string(second), "(A1 / 02/01/2021) Tj")
+	// the only line and the form total
+	assert.Equal(t, 2, strings.Count(string(second), "(7.00) Tj"))
+
+	// rows start over on each form
+	assert.Equal(t, "A1 / 02/01/2021", fieldValue(first, "checkRow1"))
+	assert.Equal(t, "A6 / 02/01/2021", fieldValue(first, "checkRow6"))
+	assert.Equal(t, "A7 / 02/01/2021", fieldValue(second, "checkRow1"))
+	assert.Equal(t, "7.00", fieldValue(second, "Excise in euroRow1"))
+	assert.Equal(t, "", fieldValue(second, "checkRow2"))
+}
+
+// fieldValue finds the value of the last form field whose name ends with suffix.  Values are UTF-16 text strings
+// and the filled fields are after the template's in the incremental update.
+func fieldValue(pdf []byte, suffix string) string {
+	i := bytes.LastIndex(pdf, []byte(suffix+")"))
+	if i < 0 {
+		return ""
+	}
+	obj := pdf[i:]
+	obj = obj[:bytes.Index(obj, []byte("endobj"))]
+	v := bytes.Index(obj, []byte("/V (\xfe\xff"))
+	if v < 0 {
+		return ""
+	}
+	var out []byte
+	for _, c := range obj[v+6:] {
+		if c == ')' {
+			break
+		}
+		if c != 0 {
+			out = append(out, c)
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,126 @@
+package svc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultFileNames is the file name template for exports.  It gives the names the tax office is used to, like
+// USA-Burke-VAT-DECEMBER2020-Invoices1.
+const DefaultFileNames = "{country}-{last}-{tax}-{month}{year}-{kind}{n}"
+
+// DefaultCountry is the mission country for accounts set up before the country was asked for
+const DefaultCountry = "USA"
+
+// FileName holds the values for the placeholders in a file name template:
+//
+//	{country}  mission country code, like USA
+//	{first}    first name
+//	{last}     last name
+//	{tax}      VAT or Excise
+//	{kind}     what the file is, like Invoices or Fuel_Form
+//	{month}    submission month
+//	{year}     submission year
+//	{n}        packet number
+//	{batch}    batch ID
+//
+// Empty values are left out along with the separator before them.
+type FileName struct {
+	Country string
+	First   string
+	Last    string
+	Tax     string
+	Kind    string
+	Month   string
+	Year    int
+	N       string
+	Batch   string
+}
+
+// ValidateFileNames checks a file name template when it is loaded.  Every export needs {kind} and {n} in its name
+// or the forms and invoices of a batch overwrite each other.  An empty template uses DefaultFileNames.
+func ValidateFileNames(template string) error {
+	if template == "" {
+		return nil
+	}
+	for _, p := range []string{"{kind}", "{n}"} {
+		if !strings.Contains(template, p) {
+			return fmt.Errorf("file name template %q needs %s so exported files don't overwrite each other", template, p)
+		}
+	}
+	_, err := FileName{Kind: "Invoices", N: "1"}.Format(template)
+	return err
+}
+
+var placeholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+// Format fills in the template.  Path separators in the values are replaced so a name can't leave the output
+// directory.
+func (f FileName) Format(template string) (string, error) {
+	if template == "" {
+		template = DefaultFileNames
+	}
+	f.Country = strings.ToUpper(f.Country)
+	if f.Country == "" {
+		f.Country = DefaultCountry
+	}
+	year := ""
+	if f.Year > 0 {
+		year = strconv.Itoa(f.Year)
+	}
+	values := map[string]string{
+		"{country}": f.Country,
+		"{first}":   f.First,
+		"{last}":    f.Last,
+		"{tax}":     f.Tax,
+		"{kind}":    f.Kind,
+		"{month}":   f.Month,
+		"{year}":    year,
+		"{n}":       f.N,
+		"{batch}":   f.Batch,
+	}
+
+	var err error
+	name := placeholder.ReplaceAllStringFunc(template, func(p string) string {
+		v, ok := values[p]
+		if !ok {
+			err = fmt.Errorf("unknown placeholder %s in file name template %q", p, template)
+		}
+		return strings.NewReplacer("/", "_", `\`, "_").Replace(v)
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.ContainsAny(name, "{}") {
+		return "", fmt.Errorf("unmatched brace in file name template %q", template)
+	}
+
+	// drop the separators left over from empty values
+	name = repeatedSeparator.ReplaceAllString(name, "$1")
+	name = strings.Trim(name, "-_. ")
+	if name == "" {
+		return "", fmt.Errorf("file name template %q gives an empty name", template)
+	}
+	return name, nil
+}
+
+// ArchiveName formats the name of the zip with every file of a batch from the same template.  The per-file
+// placeholders {tax}, {kind} and {n} are left out, and the batch ID goes at the end when the template doesn't
+// have {batch} already.
+func (f FileName) ArchiveName(template string) (string, error) {
+	if template == "" {
+		template = DefaultFileNames
+	}
+	for _, p := range []string{"{tax}", "{kind}", "{n}"} {
+		template = strings.ReplaceAll(template, p, "")
+	}
+	if !strings.Contains(template, "{batch}") {
+		template += "-{batch}"
+	}
+	f.Tax, f.Kind, f.N = "", "", ""
+	return f.Format(template)
+}
+
+var repeatedSeparator = regexp.MustCompile(`([-_ ])[-_ ]+`)
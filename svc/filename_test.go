@@ -0,0 +1,87 @@
+package svc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileName(t *testing.T) {
+	name := FileName{Country: "gbr", First: "Mari", Last: "Maasikas", Tax: "VAT", Kind: "Invoices", Month: "DECEMBER", Year: 2020, N: "2", Batch: "b1"}
+
+	tt := []struct {
+		name     string
+		f        FileName
+		template string
+		want     string
+		err      bool
+	}{
+		{"default", name, "", "GBR-Maasikas-VAT-DECEMBER2020-Invoices2", false},
+		{"no country", FileName{Last: "Burke", Tax: "Excise", Kind: "Fuel_Form", Month: "JANUARY", Year: 2021, N: "1"}, "", "USA-Burke-Excise-JANUARY2021-Fuel_Form1", false},
+		{"custom", name, "{country}-{last}-{kind}-{month}{year}-{n}", "GBR-Maasikas-Invoices-DECEMBER2020-2", false},
+		{"batch", name, "{batch}_{first}_{last}_{n}", "b1_Mari_Maasikas_2", false},
+		{"zip", FileName{Country: "DEU", Last: "Burke", Month: "December", Year: 2020, N: "b1"}, "", "DEU-Burke-December2020-b1", false},
+		{"no path", FileName{Last: "../../etc", Tax: "VAT"}, "{last}-{tax}", "etc-VAT", false},
+		{"unknown", name, "{country}-{name}", "", true},
+		{"unmatched", name, "{country-{last}", "", true},
+		{"empty", FileName{}, "{first}", "", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.f.Format(tc.template)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestValidateFileNames(t *testing.T) {
+	tt := []struct {
+		template string
+		err      bool
+	}{
+		{"", false},
+		{DefaultFileNames, false},
+		{"{batch}_{kind}_{n}", false},
+		{"{country}-{last}-{month}{year}", true},
+		{"{last}-{kind}", true},
+		{"{last}-{n}", true},
+		{"{kind}{n}-{name}", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.template, func(t *testing.T) {
+			err := ValidateFileNames(tc.template)
+			if tc.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestArchiveName(t *testing.T) {
+	name := FileName{Country: "deu", First: "Mari", Last: "Burke", Month: "December", Year: 2020, Batch: "b1"}
+
+	tt := []struct {
+		template string
+		want     string
+	}{
+		{"", "DEU-Burke-December2020-b1"},
+		{"{batch}_{first}_{last}_{kind}_{n}", "b1_Mari_Burke"},
+		{"{last}-{kind}{n}-{batch}", "Burke-b1"},
+		{"{last}-{tax}-{kind}{n}", "Burke-b1"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.template, func(t *testing.T) {
+			got, err := name.ArchiveName(tc.template)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -19,15 +19,42 @@ func TestImageStorage(t *testing.T) {
 	testImage, err := img.NewImageFromImage(i)
 	require.NoError(t, err)
 
-	db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
-	require.NoError(t, err)
+	defer func(s img.StorageOptions) { img.DefaultStorage = s }(img.DefaultStorage)
 
-	imageService := NewImageService(db)
-	if err := imageService.Upsert("test", "test", testImage); err != nil {
-		assert.NoError(t, err)
+	tt := []struct {
+		format img.Format
+		want   string
+	}{
+		{img.PNG, "png"},
+		{img.JPEG, "jpeg"},
 	}
+	for _, tc := range tt {
+		t.Run(tc.want, func(t *testing.T) {
+			img.DefaultStorage = img.StorageOptions{Format: tc.format, Quality: 80}
+
+			db, err := badger.Open(badger.DefaultOptions(t.TempDir()))
+			require.NoError(t, err)
+			defer db.Close()
 
-	recvImage, err := imageService.Get("test", "test")
-	assert.NoError(t, err)
-	assert.Equal(t, testImage, recvImage)
+			imageService := NewImageService(db)
+			if err := imageService.Upsert("test", "test", testImage); err != nil {
+				assert.NoError(t, err)
+			}
+
+			recvImage, err := imageService.Get("test", "test")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, recvImage.Format())
+			switch tc.format {
+			case img.PNG:
+				assert.Equal(t, testImage, recvImage)
+			default:
+				// lossy, but a flat color survives
+				assert.Equal(t, testImage.Bounds(), recvImage.Bounds())
+				r, g, b, _ := recvImage.At(60, 60).RGBA()
+				assert.InDelta(t, 255, r>>8, 4)
+				assert.InDelta(t, 0, g>>8, 4)
+				assert.InDelta(t, 0, b>>8, 4)
+			}
+		})
+	}
 }
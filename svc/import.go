@@ -0,0 +1,36 @@
+package svc
+
+import (
+	"github.com/BTBurke/vatinator/einvoice"
+	"github.com/BTBurke/vatinator/img"
+	"github.com/BTBurke/vatinator/ocr"
+)
+
+// NewReceiptFromInvoice creates a receipt from a structured e-invoice.  All of the values come directly from the
+// invoice, so there are no extraction errors to review.
+func NewReceiptFromInvoice(inv einvoice.Invoice) *Receipt {
+	precision := Digit2
+	if inv.Precision == 3 {
+		precision = Digit3
+	}
+	var subtotals []TaxSubtotal
+	for _, sub := range inv.TaxSubtotals {
+		subtotals = append(subtotals, TaxSubtotal{Rate: sub.Rate, Taxable: sub.Taxable, VAT: sub.VAT})
+	}
+	return &Receipt{
+		Vendor:            inv.Vendor,
+		TaxID:             inv.RegCode,
+		ReceiptNumber:     inv.Number,
+		Date:              inv.Date,
+		Total:             inv.Total,
+		VAT:               inv.VAT,
+		CurrencyPrecision: precision,
+		DocumentType:      string(ocr.Invoice),
+		TaxSubtotals:      subtotals,
+	}
+}
+
+// RenderInvoice creates the page image that goes in the invoice packet for an e-invoice
+func RenderInvoice(inv einvoice.Invoice) (img.Image, error) {
+	return img.RenderText(inv.Lines())
+}
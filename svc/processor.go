@@ -1,6 +1,7 @@
 package svc
 
 import (
+	"image"
 	"log"
 	"os"
 	"strings"
@@ -17,6 +18,10 @@ var cache map[string]Processor
 
 type Processor interface {
 	Add(name string, image img.Image) error
+	// AddFrom is like Add but records where the image came from, like the email it was attached to
+	AddFrom(name string, image img.Image, source *Provenance) error
+	// Import saves a receipt that was read from a structured document, skipping OCR
+	Import(name string, receipt *Receipt, image img.Image) error
 	Wait() error
 }
 
@@ -45,7 +50,17 @@ type singleProcessor struct {
 }
 
 func (s *singleProcessor) Add(name string, image img.Image) error {
-	return process(s.db, s.accountID, s.batchID, name, image, s.keyPath, nil)
+	return s.AddFrom(name, image, nil)
+}
+func (s *singleProcessor) AddFrom(name string, image img.Image, source *Provenance) error {
+	return process(s.db, s.accountID, s.batchID, name, image, source, &ParallelOptions{
+		KeyPath:    s.keyPath,
+		Preprocess: &img.DefaultPreprocessOptions,
+		Quality:    &img.DefaultQualityOptions,
+	})
+}
+func (s *singleProcessor) Import(name string, receipt *Receipt, image img.Image) error {
+	return importReceipt(s.db, s.accountID, s.batchID, name, receipt, image, nil)
 }
 func (s *singleProcessor) Wait() error {
 	// returns immediately - synchronous
@@ -64,8 +79,9 @@ type parallelProcessor struct {
 }
 
 type parallelTask struct {
-	name  string
-	image img.Image
+	name   string
+	image  img.Image
+	source *Provenance
 }
 
 // ParallelOptions set options on a parallel image processor
@@ -78,6 +94,12 @@ type ParallelOptions struct {
 	KeyPath string
 	// Hooks to execute before/after processing the batch and receipts
 	Hooks *Hooks
+	// Preprocess prepares images before OCR (default: img.DefaultPreprocessOptions).  Use an empty
+	// PreprocessOptions to send the original image.
+	Preprocess *img.PreprocessOptions
+	// Quality sets the limits for photo quality warnings and whether hopeless photos skip OCR
+	// (default: img.DefaultQualityOptions)
+	Quality *img.QualityOptions
 }
 
 func NewParallelProcessor(db *badger.DB, accountID string, batchID string, opts *ParallelOptions) Processor {
@@ -89,6 +111,13 @@ func NewParallelProcessor(db *badger.DB, accountID string, batchID string, opts
 		}
 	}
 
+	if opts.Preprocess == nil {
+		opts.Preprocess = &img.DefaultPreprocessOptions
+	}
+	if opts.Quality == nil {
+		opts.Quality = &img.DefaultQualityOptions
+	}
+
 	if opts.Hooks != nil && opts.Hooks.BeforeStart != nil {
 		opts.Hooks.BeforeStart()
 	}
@@ -101,7 +130,7 @@ func NewParallelProcessor(db *badger.DB, accountID string, batchID string, opts
 		go func(ch chan parallelTask, db *badger.DB, accountID string, batchID string) {
 			defer wg.Done()
 			for task := range ch {
-				if err := process(db, accountID, batchID, task.name, task.image, opts.KeyPath, opts.Hooks); err != nil {
+				if err := process(db, accountID, batchID, task.name, task.image, task.source, opts); err != nil {
 					log.Printf("processing error: %s", err)
 				}
 			}
@@ -120,10 +149,19 @@ func NewParallelProcessor(db *badger.DB, accountID string, batchID string, opts
 }
 
 func (p *parallelProcessor) Add(name string, image img.Image) error {
-	p.ch <- parallelTask{name: name, image: image}
+	return p.AddFrom(name, image, nil)
+}
+
+func (p *parallelProcessor) AddFrom(name string, image img.Image, source *Provenance) error {
+	p.ch <- parallelTask{name: name, image: image, source: source}
 	return nil
 }
 
+// Import runs synchronously because there is no OCR to wait on
+func (p *parallelProcessor) Import(name string, receipt *Receipt, image img.Image) error {
+	return importReceipt(p.db, p.accountID, p.batchID, name, receipt, image, p.hooks)
+}
+
 func (p *parallelProcessor) Wait() error {
 	close(p.ch)
 	p.wg.Wait()
@@ -134,11 +172,37 @@ func (p *parallelProcessor) Wait() error {
 }
 
 // process image and save image and result to database
-func process(db *badger.DB, accountID string, batchID string, name string, image img.Image, keyPath string, hooks *Hooks) error {
+func process(db *badger.DB, accountID string, batchID string, name string, image img.Image, source *Provenance, opts *ParallelOptions) error {
+	hooks := opts.Hooks
 	if hooks != nil && hooks.BeforeEach != nil {
 		// TODO: figure out how to do before each
 	}
-	result, err := ocr.ProcessImage(image, keyPath)
+
+	// photos taken at an angle are warped flat so the background around the receipt is gone before OCR, and the
+	// crop and the invoice packet use the flat image too
+	image, _, err := img.Flatten(image)
+	if err != nil {
+		log.Printf("failed to flatten %s: %s", name, err)
+	}
+
+	// check the photo before spending an OCR call on it
+	quality := img.AnalyzeQuality(image)
+	warnings := quality.Warnings(opts.Quality)
+	if opts.Quality != nil && opts.Quality.Reject && quality.Hopeless(opts.Quality) {
+		receipt := &Receipt{
+			ID:           xid.New().String(),
+			Filename:     name,
+			BatchID:      batchID,
+			Errors:       []string{"photo rejected before OCR"},
+			Warnings:     warnings,
+			Rejected:     true,
+			RulesVersion: ocr.RulesVersion,
+			Provenance:   source,
+		}
+		return save(db, accountID, name, receipt, image, hooks)
+	}
+
+	result, err := ocr.ProcessImageWithOptions(image, opts.KeyPath, opts.Preprocess)
 	if err != nil {
 		return errors.Wrapf(err, "failed to vision process %s", name)
 	}
@@ -173,7 +237,11 @@ func process(db *badger.DB, accountID string, batchID string, name string, image
 		BatchID:           batchID,
 		Errors:            result.Errors,
 		RulesVersion:      ocr.RulesVersion,
-		CurrencyPrecision: Digit2,
+		CurrencyPrecision: precisionFromOCR(result.Precision),
+		DocumentType:      string(result.Document),
+		Provenance:        source,
+		Warnings:          warnings,
+		Words:             textWords(result.Layout, croppedImage.Bounds(), int(result.Crop.Left), int(result.Crop.Top)),
 	}
 	if result.Excise != nil {
 		receipt.IsExcise = true
@@ -181,12 +249,27 @@ func process(db *badger.DB, accountID string, batchID string, name string, image
 		receipt.ExciseAmount = result.Excise.Amount
 	}
 
+	return save(db, accountID, name, receipt, croppedImage, hooks)
+}
+
+// importReceipt saves a receipt that was created from a structured document instead of OCR
+func importReceipt(db *badger.DB, accountID string, batchID string, name string, receipt *Receipt, image img.Image, hooks *Hooks) error {
+	if len(receipt.ID) == 0 {
+		receipt.ID = xid.New().String()
+	}
+	receipt.Filename = name
+	receipt.BatchID = batchID
+	return save(db, accountID, name, receipt, image, hooks)
+}
+
+// save persists the receipt and image to the database then runs the after each hook
+func save(db *badger.DB, accountID string, name string, receipt *Receipt, image img.Image, hooks *Hooks) error {
 	if err := db.Update(func(txn *badger.Txn) error {
 
 		if err := upsertReceipt(txn, accountID, receipt); err != nil {
 			return err
 		}
-		if err := upsertImage(txn, accountID, receipt.ID, croppedImage); err != nil {
+		if err := upsertImage(txn, accountID, receipt.ID, image); err != nil {
 			return err
 		}
 		return nil
@@ -203,4 +286,40 @@ func process(db *badger.DB, accountID string, batchID string, name string, image
 	return nil
 }
 
+// textWords moves the words from OCR into the coordinates of the cropped image
+func textWords(layout *ocr.Layout, bounds image.Rectangle, left int, top int) []img.Word {
+	if layout == nil {
+		return nil
+	}
+	// same as the padding CropImage keeps around the text
+	left = max(0, left-img.CropPadding)
+	top = max(0, top-img.CropPadding)
+
+	var out []img.Word
+	for _, w := range layout.Words {
+		r := image.Rect(int(w.Box.Left)-left, int(w.Box.Top)-top, int(w.Box.Right)-left, int(w.Box.Bottom)-top).Intersect(bounds)
+		if !r.Empty() {
+			out = append(out, img.Word{Text: w.Text, Box: r})
+		}
+	}
+	return out
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
+
+// precisionFromOCR maps the precision detected by the currency rule to the precision stored on the receipt
+func precisionFromOCR(p ocr.CurrencyPrecision) Precision {
+	switch p {
+	case ocr.Currency3:
+		return Digit3
+	default:
+		return Digit2
+	}
+}
+
 var _ Processor = &singleProcessor{}
@@ -2,7 +2,9 @@ package svc
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -20,32 +22,73 @@ type Hooks struct {
 
 type ReceiptHook func(r *Receipt) error
 
-// WriteErrors writes errors to a file after each receipt is processed
+// ReviewFile is the name of the file in the output directory that lists errors and documents that need review
+const ReviewFile string = "errors.txt"
+
+// WriteErrors writes errors to a file after each receipt is processed.  Documents that can't be claimed are
+// skipped because they are listed in their own section of the file during export, when WriteReview replaces the
+// whole file.
 func WriteErrors(file string) ReceiptHook {
 	var mu sync.Mutex
 	return func(r *Receipt) error {
+		if !r.IsClaimable() {
+			return nil
+		}
 		mu.Lock()
 		defer mu.Unlock()
 
-		var f *os.File
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			f, err = os.Create(file)
-			if err != nil {
-				return err
-			}
-		} else {
-			f, err = os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-			if err != nil {
-				return err
-			}
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
 		}
 		defer f.Close()
 
-		for _, e := range r.Errors {
-			if _, err := f.Write([]byte(fmt.Sprintf("%s: %s\n", r.Filename, e))); err != nil {
-				return err
-			}
+		_, err = f.Write([]byte(reviewLines(*r)))
+		return err
+	}
+}
+
+// WriteReview writes the review file for an export: the errors and warnings of the receipts on the forms, then a
+// section listing the documents that were left off.  It replaces the file so that exporting a batch again doesn't
+// repeat anything.
+func WriteReview(file string, receipts []Receipt, excluded []Receipt) error {
+	var out strings.Builder
+	for _, r := range receipts {
+		out.WriteString(reviewLines(r))
+	}
+
+	var documents, rejected []Receipt
+	for _, r := range excluded {
+		if r.Rejected {
+			rejected = append(rejected, r)
+		} else {
+			documents = append(documents, r)
 		}
-		return nil
 	}
+	if len(documents) > 0 {
+		out.WriteString("\nNot included on the forms because they are not receipts or invoices:\n")
+		for _, r := range documents {
+			out.WriteString(fmt.Sprintf("%s: %s\n", r.Filename, r.DocumentType))
+		}
+	}
+	if len(rejected) > 0 {
+		out.WriteString("\nNot included on the forms because the photo can't be read, please retake them:\n")
+		for _, r := range rejected {
+			out.WriteString(fmt.Sprintf("%s: %s\n", r.Filename, strings.Join(r.Warnings, "; ")))
+		}
+	}
+
+	return ioutil.WriteFile(file, []byte(out.String()), 0644)
+}
+
+// reviewLines are the errors and warnings of a receipt in the review file
+func reviewLines(r Receipt) string {
+	var out strings.Builder
+	for _, e := range r.Errors {
+		out.WriteString(fmt.Sprintf("%s: %s\n", r.Filename, e))
+	}
+	for _, w := range r.Warnings {
+		out.WriteString(fmt.Sprintf("%s: warning: %s\n", r.Filename, w))
+	}
+	return out.String()
 }
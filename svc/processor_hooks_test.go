@@ -0,0 +1,53 @@
+package svc
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ReviewFile)
+
+	hook := WriteErrors(file)
+	require.NoError(t, hook(&Receipt{Filename: "receipt.jpg", Errors: []string{"no date found"}, DocumentType: "fiscal receipt"}))
+	require.NoError(t, hook(&Receipt{Filename: "old.jpg", Errors: []string{"no vendor found"}}))
+	require.NoError(t, hook(&Receipt{Filename: "slip.jpg", Errors: []string{"no tax/total found"}, DocumentType: "card slip"}))
+
+	out, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "receipt.jpg: no date found\nold.jpg: no vendor found\n", string(out))
+
+	receipts := []Receipt{
+		{Filename: "receipt.jpg", Errors: []string{"no date found"}, DocumentType: "fiscal receipt"},
+		{Filename: "old.jpg", Errors: []string{"no vendor found"}},
+	}
+	excluded := []Receipt{{Filename: "slip.jpg", DocumentType: "card slip"}}
+	want := "receipt.jpg: no date found\nold.jpg: no vendor found\n\nNot included on the forms because they are not receipts or invoices:\nslip.jpg: card slip\n"
+
+	// exporting again replaces the file instead of adding the sections again
+	for i := 0; i < 2; i++ {
+		require.NoError(t, WriteReview(file, receipts, excluded))
+		out, err = ioutil.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(out))
+	}
+}
+
+func TestReviewFileQuality(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ReviewFile)
+
+	dark := Receipt{Filename: "dark.jpg", Warnings: []string{"photo is too dark, take it in better light"}}
+	blurry := Receipt{Filename: "blurry.jpg", Errors: []string{"photo rejected before OCR"}, Warnings: []string{"photo is blurry", "photo is too dark"}, Rejected: true}
+	hook := WriteErrors(file)
+	require.NoError(t, hook(&dark))
+	require.NoError(t, hook(&blurry))
+	require.NoError(t, WriteReview(file, []Receipt{dark}, []Receipt{blurry}))
+
+	out, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "dark.jpg: warning: photo is too dark, take it in better light\n\nNot included on the forms because the photo can't be read, please retake them:\nblurry.jpg: photo is blurry; photo is too dark\n", string(out))
+}
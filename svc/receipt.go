@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/BTBurke/vatinator/db"
+	"github.com/BTBurke/vatinator/img"
+	"github.com/BTBurke/vatinator/ocr"
 	"github.com/BTBurke/vatinator/xls"
 )
 
@@ -47,6 +49,35 @@ type Receipt struct {
 	IsExcise     bool
 	ExciseType   string
 	ExciseAmount string
+	// DocumentType is the kind of document detected in the image.  Card slips and other documents that are not
+	// receipts or invoices are left off the forms.  Empty for receipts processed before classification existed.
+	DocumentType string
+	// TaxSubtotals break the VAT down by rate when the receipt came from a structured e-invoice
+	TaxSubtotals []TaxSubtotal `json:",omitempty"`
+	// Provenance is set when the receipt arrived in an email
+	Provenance *Provenance `json:",omitempty"`
+	// Warnings about the photo quality that explain how to take a better photo
+	Warnings []string `json:",omitempty"`
+	// Rejected is set when the photo was too poor to send for OCR.  Rejected receipts are left off the forms.
+	Rejected bool `json:",omitempty"`
+	// Words found by OCR in pixels of the stored image.  They keep the stamp off the text and make the invoice
+	// packet searchable.
+	Words []img.Word `json:",omitempty"`
+	// Stamp is where the stamp was put the first time the receipt was exported
+	Stamp *img.StampPlacement `json:",omitempty"`
+}
+
+// Provenance records the email a receipt was attached to
+type Provenance struct {
+	From    string
+	Subject string
+}
+
+// TaxSubtotal is the taxable amount and VAT at a single VAT rate, in the same precision as the receipt
+type TaxSubtotal struct {
+	Rate    string
+	Taxable int
+	VAT     int
 }
 
 func (r *Receipt) Type() byte {
@@ -65,6 +96,11 @@ func (r *Receipt) UnmarshalBinary(data []byte) error {
 	return json.Unmarshal(data, r)
 }
 
+// IsClaimable is true when the receipt can be used on the VAT and excise forms
+func (r *Receipt) IsClaimable() bool {
+	return !r.Rejected && ocr.DocumentType(r.DocumentType).Claimable()
+}
+
 func (r *Receipt) GetVendor() string {
 	return r.Vendor
 }
@@ -82,20 +118,49 @@ func (r *Receipt) GetDate() string {
 }
 
 func (r *Receipt) GetTotal() string {
-	switch r.CurrencyPrecision {
-	case Digit3:
-		return currency3ToString(r.Total)
+	return formatCurrency(r.Total, r.CurrencyPrecision)
+}
+
+func (r *Receipt) GetVAT() string {
+	return formatCurrency(r.VAT, r.CurrencyPrecision)
+}
+
+// TotalAs returns the total converted to precision p.  Converting from 3 digits to 2 digits rounds to the
+// nearest cent.
+func (r *Receipt) TotalAs(p Precision) int {
+	return convertPrecision(r.Total, r.CurrencyPrecision, p)
+}
+
+// VATAs returns the VAT converted to precision p.  Converting from 3 digits to 2 digits rounds to the
+// nearest cent.
+func (r *Receipt) VATAs(p Precision) int {
+	return convertPrecision(r.VAT, r.CurrencyPrecision, p)
+}
+
+func convertPrecision(d int, from Precision, to Precision) int {
+	if from == 0 {
+		from = Digit2
+	}
+	if to == 0 {
+		to = Digit2
+	}
+	switch {
+	case from == Digit2 && to == Digit3:
+		return d * 10
+	case from == Digit3 && to == Digit2:
+		return (d + 5) / 10
 	default:
-		return currency2ToString(r.Total)
+		return d
 	}
 }
 
-func (r *Receipt) GetVAT() string {
-	switch r.CurrencyPrecision {
+// formatCurrency formats d as a decimal string with the given precision
+func formatCurrency(d int, p Precision) string {
+	switch p {
 	case Digit3:
-		return currency3ToString(r.VAT)
+		return currency3ToString(d)
 	default:
-		return currency2ToString(r.VAT)
+		return currency2ToString(d)
 	}
 }
 
@@ -105,3 +105,18 @@ func TestCurrencyConv(t *testing.T) {
 		})
 	}
 }
+
+func TestPrecisionConversion(t *testing.T) {
+	r2 := &Receipt{Total: 1234, VAT: 206, CurrencyPrecision: Digit2}
+	r3 := &Receipt{Total: 140000, VAT: 23333, CurrencyPrecision: Digit3}
+	unset := &Receipt{Total: 500, VAT: 83}
+
+	assert.Equal(t, 12340, r2.TotalAs(Digit3))
+	assert.Equal(t, 2060, r2.VATAs(Digit3))
+	assert.Equal(t, 14000, r3.TotalAs(Digit2))
+	assert.Equal(t, 2333, r3.VATAs(Digit2))
+	assert.Equal(t, 23333, r3.VATAs(Digit3))
+	assert.Equal(t, 5000, unset.TotalAs(Digit3))
+	assert.Equal(t, "23.333", r3.GetVAT())
+	assert.Equal(t, "5.00", unset.GetTotal())
+}
@@ -0,0 +1,248 @@
+package svc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/jpeg"
+	"os"
+	"strings"
+
+	"github.com/BTBurke/vatinator/img"
+	"github.com/BTBurke/vatinator/pdf"
+	"github.com/BTBurke/vatinator/types"
+	"github.com/dgraph-io/badger/v2"
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+)
+
+// placement is where a receipt is on the forms.  Packets and lines start at 1 and are 0 when the receipt isn't on
+// that form.
+type placement struct {
+	VATPacket    int
+	VATLine      int
+	ExcisePacket int
+	ExciseLine   int
+}
+
+// placements finds the form and line of each claimable receipt, in the same order the forms are written
+func placements(receipts []Receipt, vatLines int) []placement {
+	out := make([]placement, len(receipts))
+	var excises int
+	for i, r := range receipts {
+		out[i].VATPacket = i/vatLines + 1
+		out[i].VATLine = i%vatLines + 1
+		if r.IsExcise {
+			out[i].ExcisePacket = excises/pdf.MaxExciseLines + 1
+			out[i].ExciseLine = excises%pdf.MaxExciseLines + 1
+			excises++
+		}
+	}
+	return out
+}
+
+// exciseFor is the line of the excise form for a fuel receipt
+func exciseFor(r Receipt) types.Excise {
+	return types.Excise{
+		Type:    r.ExciseType,
+		Amount:  r.ExciseAmount,
+		Arve:    r.ReceiptNumber,
+		Content: "", // empty string for gas receipts
+		Date:    r.Date,
+	}
+}
+
+// totals sums the receipts.  The totals use 3 digits if any receipt does.
+func totals(receipts []Receipt) (total string, vat string) {
+	precision := Digit2
+	for _, r := range receipts {
+		if r.CurrencyPrecision == Digit3 {
+			precision = Digit3
+		}
+	}
+	var t, v int
+	for i := range receipts {
+		t += receipts[i].TotalAs(precision)
+		v += receipts[i].VATAs(precision)
+	}
+	return formatCurrency(t, precision), formatCurrency(v, precision)
+}
+
+// thumbnailSize is the largest width and height of a thumbnail in pixels
+const thumbnailSize = 300
+
+// writeSummary writes the batch report as HTML and PDF.  Thumbnails are left out for receipts without an image,
+// which happens for photos rejected before they were stored.
+func writeSummary(txn *badger.Txn, accountID string, batchID string, receipts []Receipt, excluded []Receipt, vatLines int, opts *ExportOptions) error {
+	thumbnail := func(id string) []byte {
+		image, err := getImage(txn, accountID, id)
+		if err != nil {
+			return nil
+		}
+		b, err := thumbnailJPG(image)
+		if err != nil {
+			return nil
+		}
+		return b
+	}
+
+	s := summary(receipts, excluded, vatLines, opts)
+	s.Batch = batchID
+	for i := range s.Receipts {
+		s.Receipts[i].Thumbnail = thumbnail(receipts[i].ID)
+	}
+	for i := range s.Excluded {
+		s.Excluded[i].Thumbnail = thumbnail(excluded[i].ID)
+	}
+
+	hpath, err := opts.path("", "Summary", 0, batchID, ".html")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(hpath)
+	if err != nil {
+		return errors.Wrap(err, "failed to create summary")
+	}
+	defer f.Close()
+	if err := summaryTemplate.Execute(f, s); err != nil {
+		return errors.Wrap(err, "failed to write summary")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "failed to write summary")
+	}
+
+	ppath := strings.TrimSuffix(hpath, ".html") + ".pdf"
+	if err := pdf.WriteSummary(ppath, s); err != nil {
+		return errors.Wrapf(err, "failed to save summary PDF to %s", ppath)
+	}
+	return nil
+}
+
+// summary lists the receipts on the forms in form order, followed by the ones left off
+func summary(receipts []Receipt, excluded []Receipt, vatLines int, opts *ExportOptions) pdf.Summary {
+	s := pdf.Summary{
+		Name:   opts.FullName,
+		Period: strings.TrimSpace(fmt.Sprintf("%s %d", opts.Month, opts.Year)),
+	}
+	s.Total, s.VAT = totals(receipts)
+
+	var excise int
+	for i, p := range placements(receipts, vatLines) {
+		r := receipts[i]
+		l := summaryLine(r)
+		l.Forms = append(l.Forms, fmt.Sprintf("VAT %d line %d", p.VATPacket, p.VATLine))
+		if p.ExcisePacket > 0 {
+			l.Forms = append(l.Forms, fmt.Sprintf("Excise %d line %d", p.ExcisePacket, p.ExciseLine))
+			excise += exciseFor(r).TaxAmount()
+		}
+		s.Receipts = append(s.Receipts, l)
+	}
+	s.Excise = types.Currency(excise).String()
+
+	for _, r := range excluded {
+		l := summaryLine(r)
+		l.Reason = excludedReason(r)
+		s.Excluded = append(s.Excluded, l)
+	}
+	return s
+}
+
+// excludedReason is why a receipt was left off the forms
+func excludedReason(r Receipt) string {
+	if r.Rejected {
+		return "the photo can't be read, please retake it"
+	}
+	return fmt.Sprintf("not a receipt or invoice (%s)", r.DocumentType)
+}
+
+func summaryLine(r Receipt) pdf.SummaryLine {
+	l := pdf.SummaryLine{
+		Filename:      r.Filename,
+		Vendor:        r.Vendor,
+		TaxID:         r.TaxID,
+		Date:          r.Date,
+		ReceiptNumber: r.ReceiptNumber,
+		Total:         r.GetTotal(),
+		VAT:           r.GetVAT(),
+	}
+	if r.IsExcise {
+		l.ExciseType = r.ExciseType
+		l.ExciseAmount = r.ExciseAmount
+		l.ExciseTax = types.Currency(exciseFor(r).TaxAmount()).String()
+	}
+	l.Problems = append(l.Problems, r.Errors...)
+	for _, w := range r.Warnings {
+		l.Problems = append(l.Problems, "warning: "+w)
+	}
+	return l
+}
+
+func thumbnailJPG(image img.Image) ([]byte, error) {
+	t := resize.Thumbnail(thumbnailSize, thumbnailSize, image.GetImage(), resize.Bilinear)
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, t, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+var summaryTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"thumbnail": func(b []byte) template.URL {
+		return template.URL("data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(b))
+	},
+	"join": strings.Join,
+}).Parse(summaryHTML))
+
+const summaryHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt summary {{.Batch}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; font-size: 14px; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px 10px; text-align: left; vertical-align: top; }
+td.amount { text-align: right; }
+img { max-width: 120px; max-height: 180px; }
+.problem { color: #b00000; }
+</style>
+</head>
+<body>
+<h1>Receipt summary</h1>
+<p>{{with .Name}}{{.}}<br>{{end}}{{with .Period}}{{.}}<br>{{end}}Batch {{.Batch}}</p>
+
+<h2>Totals</h2>
+<table>
+<tr><th>Receipts on the forms</th><td class="amount">{{len .Receipts}}</td></tr>
+<tr><th>Total incl. VAT</th><td class="amount">{{.Total}}</td></tr>
+<tr><th>VAT</th><td class="amount">{{.VAT}}</td></tr>
+<tr><th>Excise</th><td class="amount">{{.Excise}}</td></tr>
+<tr><th>Need attention</th><td class="amount">{{len .NeedsAttention}}</td></tr>
+<tr><th>Left off the forms</th><td class="amount">{{len .Excluded}}</td></tr>
+</table>
+
+{{with .NeedsAttention}}
+<h2>Need attention</h2>
+<table>
+<tr><th>File</th><th>Forms</th><th>Problems</th></tr>
+{{range .}}<tr><td>{{.Filename}}</td><td>{{join .Forms ", "}}</td><td class="problem">{{range .Problems}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+{{with .Excluded}}
+<h2>Left off the forms</h2>
+<table>
+<tr><th></th><th>File</th><th>Reason</th><th>Problems</th></tr>
+{{range .}}<tr><td>{{with .Thumbnail}}<img src="{{thumbnail .}}">{{end}}</td><td>{{.Filename}}</td><td>{{.Reason}}</td><td class="problem">{{range .Problems}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Receipts on the forms</h2>
+<table>
+<tr><th></th><th>File</th><th>Vendor</th><th>Tax ID</th><th>Date</th><th>Receipt</th><th>Total</th><th>VAT</th><th>Excise</th><th>Forms</th><th>Problems</th></tr>
+{{range .Receipts}}<tr><td>{{with .Thumbnail}}<img src="{{thumbnail .}}">{{end}}</td><td>{{.Filename}}</td><td>{{.Vendor}}</td><td>{{.TaxID}}</td><td>{{.Date}}</td><td>{{.ReceiptNumber}}</td><td class="amount">{{.Total}}</td><td class="amount">{{.VAT}}</td><td>{{if .ExciseTax}}{{.ExciseType}} {{.ExciseAmount}}<br>tax {{.ExciseTax}}{{end}}</td><td>{{join .Forms ", "}}</td><td class="problem">{{range .Problems}}{{.}}<br>{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
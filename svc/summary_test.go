@@ -0,0 +1,64 @@
+package svc
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	receipts := []Receipt{
+		{Filename: "rimi.jpg", Vendor: "Rimi", Date: "02/01/2021", Total: 1200, VAT: 200, CurrencyPrecision: Digit2},
+		{Filename: "alexela.jpg", Vendor: "Alexela", Date: "03/01/2021", Total: 3000, VAT: 500, CurrencyPrecision: Digit2,
+			IsExcise: true, ExciseType: "Gas 95", ExciseAmount: "20.00", Warnings: []string{"blurry"}},
+		{Filename: "selver.jpg", Vendor: "Selver", Date: "04/01/2021", Total: 100, VAT: 17, CurrencyPrecision: Digit2,
+			Errors: []string{"no receipt number"}},
+	}
+	excluded := []Receipt{
+		{Filename: "slip.jpg", DocumentType: "card slip"},
+		{Filename: "dark.jpg", Rejected: true, Warnings: []string{"too dark"}},
+	}
+	s := summary(receipts, excluded, 2, &ExportOptions{FullName: "Mari Maasikas", Month: "JANUARY", Year: 2021})
+
+	assert.Equal(t, "JANUARY 2021", s.Period)
+	assert.Equal(t, "43.00", s.Total)
+	assert.Equal(t, "7.17", s.VAT)
+	// 20 liters at 0.563
+	assert.Equal(t, "11.26", s.Excise)
+
+	require.Len(t, s.Receipts, 3)
+	assert.Equal(t, []string{"VAT 1 line 1"}, s.Receipts[0].Forms)
+	assert.Equal(t, []string{"VAT 1 line 2", "Excise 1 line 1"}, s.Receipts[1].Forms)
+	assert.Equal(t, "11.26", s.Receipts[1].ExciseTax)
+	assert.Equal(t, []string{"VAT 2 line 1"}, s.Receipts[2].Forms)
+
+	attention := s.NeedsAttention()
+	require.Len(t, attention, 2)
+	assert.Equal(t, []string{"warning: blurry"}, attention[0].Problems)
+	assert.Equal(t, []string{"no receipt number"}, attention[1].Problems)
+
+	require.Len(t, s.Excluded, 2)
+	assert.Contains(t, s.Excluded[0].Reason, "card slip")
+	assert.Contains(t, s.Excluded[1].Reason, "retake")
+
+	s.Receipts[0].Thumbnail = []byte{0xff, 0xd8}
+	var b bytes.Buffer
+	require.NoError(t, summaryTemplate.Execute(&b, s))
+	for _, want := range []string{"alexela.jpg", "VAT 1 line 2, Excise 1 line 1", "no receipt number", "slip.jpg", "data:image/jpeg;base64,/9g=", "11.26"} {
+		assert.Contains(t, b.String(), want)
+	}
+}
+
+func TestPlacements(t *testing.T) {
+	var receipts []Receipt
+	for i := 0; i < 20; i++ {
+		receipts = append(receipts, Receipt{IsExcise: i%2 == 0})
+	}
+	p := placements(receipts, 17)
+	assert.Equal(t, placement{VATPacket: 1, VATLine: 1, ExcisePacket: 1, ExciseLine: 1}, p[0])
+	assert.Equal(t, placement{VATPacket: 1, VATLine: 2}, p[1])
+	assert.Equal(t, placement{VATPacket: 1, VATLine: 13, ExcisePacket: 2, ExciseLine: 1}, p[12])
+	assert.Equal(t, placement{VATPacket: 2, VATLine: 2, ExcisePacket: 2, ExciseLine: 4}, p[18])
+}
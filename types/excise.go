@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
 )
 
 const GasTaxRate float64 = 0.563
@@ -21,13 +22,8 @@ type Excise struct {
 // AsMap is called before exporting this receipt to the excise form.  If the tax is not explicitly set,
 // it will be calculated automatically based on the current rate.
 func (e *Excise) AsMap(i int) map[string]string {
-	var tax int
-	if e.Tax == 0 {
-		tax = calculateTax(e.Amount, GasTaxRate)
-		e.Tax = tax
-	} else {
-		tax = e.Tax
-	}
+	tax := e.TaxAmount()
+	e.Tax = tax
 	return map[string]string{
 		makeKey("type", i):    maybe(e.Type),
 		makeKey("content", i): e.Content,
@@ -37,6 +33,14 @@ func (e *Excise) AsMap(i int) map[string]string {
 	}
 }
 
+// TaxAmount is the excise tax in cents, calculated from the amount at the current rate if it isn't set
+func (e Excise) TaxAmount() int {
+	if e.Tax == 0 {
+		return calculateTax(e.Amount, GasTaxRate)
+	}
+	return e.Tax
+}
+
 // ExciseMetadata at the top of the excise form
 type ExciseMetadata struct {
 	Embassy string
@@ -45,12 +49,41 @@ type ExciseMetadata struct {
 	Date    string
 }
 
+// calculateTax returns the excise tax in cents, rounded up.  The amount and rate are converted to thousandths
+// and multiplied as integers so that amounts with 3 decimal places don't pick up floating point error.
 func calculateTax(amt string, rate float64) int {
-	amtF, err := strconv.ParseFloat(amt, 64)
+	amtMilli, err := parseThousandths(amt)
 	if err != nil {
 		return 0
 	}
-	return int(math.Ceil(rate * amtF * 100))
+	rateMilli := int64(math.Round(rate * 1000))
+
+	// product is in millionths of a euro, and 10000 millionths is one cent
+	product := amtMilli * rateMilli
+	return int((product + 9999) / 10000)
+}
+
+// parseThousandths parses a decimal string like 34.35 or 34,355 to an integer number of thousandths
+func parseThousandths(s string) (int64, error) {
+	s = strings.ReplaceAll(strings.TrimSpace(s), ",", ".")
+	parts := strings.SplitN(s, ".", 2)
+	whole, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	var frac int64
+	if len(parts) == 2 && len(parts[1]) > 0 {
+		digits := parts[1]
+		if len(digits) > 3 {
+			digits = digits[0:3]
+		}
+		digits += strings.Repeat("0", 3-len(digits))
+		frac, err = strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return whole*1000 + frac, nil
 }
 
 func makeKey(f string, i int) string {
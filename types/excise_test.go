@@ -14,6 +14,9 @@ func TestExciseTaxCalculation(t *testing.T) {
 		{in: "40.0", out: 2252},
 		{in: "40", out: 2252},
 		{in: "72.8", out: 4099},
+		{in: "34.355", out: 1935},
+		{in: "39,80", out: 2241},
+		{in: "", out: 0},
 	}
 	for _, tc := range tt {
 		assert.Equal(t, tc.out, calculateTax(tc.in, GasTaxRate))
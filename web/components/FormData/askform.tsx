@@ -10,6 +10,7 @@ export function AskForm(props): JSX.Element {
         last_name: "",
         diplomatic_id: "",
         embassy: "US Embassy",
+        country: "USA",
         address: "Kentmanni 20",
         bank_name: "",
         account: "",
@@ -69,6 +69,13 @@ export function FormEmbassyData(props): JSX.Element {
               {errors.first_name && <span className="text-red-800 text-bold">This field is required</span>}
           </div>
         </div>
+        <div className="flex flex-col md:flex-row">
+          <div className="w-full md:w-1/2 md:pr-2 py-2">
+              <p className="text-gray-500 text-bold text-lg">Mission Country Code</p>
+              <input {...register("country", {required: true, pattern: /^[A-Za-z]{3}$/})} placeholder="USA" className="mt-1 py-1 appearance-none rounded bg-secondary text-white text-lg w-full leading-tight" />
+              {errors.country && <span className="text-red-800 text-bold">Enter the 3 letter country code, like USA</span>}
+          </div>
+        </div>
   
         <div className="py-6">
           <input type="submit" value="Next" className="px-8 bg-accent-2 text-white py-2 rounded-md font-bold border border-accent-2"/>
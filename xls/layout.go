@@ -0,0 +1,156 @@
+package xls
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BTBurke/vatinator/bundled"
+	"github.com/pkg/errors"
+	"github.com/tealeg/xlsx/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// LayoutsAsset describes every version of the VAT form template
+const LayoutsAsset = "assets/vat-templates.yaml"
+
+// Layout is where one version of the VAT form template keeps its fields.  Layouts are loaded from
+// assets/vat-templates.yaml so a new template from the Foreign Ministry doesn't need a code change.
+type Layout struct {
+	Version string `yaml:"version"`
+	// From is the first submission date this version is used for, as YYYY-MM-DD
+	From string `yaml:"from"`
+	// Template is the name of the bundled xlsx asset
+	Template string `yaml:"template"`
+	// MaxLines is the number of receipt lines on one form
+	MaxLines int `yaml:"max_lines"`
+	Cells    struct {
+		Name         []Cell `yaml:"name"`
+		DiplomaticID []Cell `yaml:"diplomatic_id"`
+		Bank         []Cell `yaml:"bank"`
+		Month        []Cell `yaml:"month"`
+	} `yaml:"cells"`
+	Lines struct {
+		// FirstRow is the row of the first receipt line
+		FirstRow int `yaml:"first_row"`
+		Columns  struct {
+			Number        int `yaml:"number"`
+			Vendor        int `yaml:"vendor"`
+			ReceiptNumber int `yaml:"receipt_number"`
+			Date          int `yaml:"date"`
+			Total         int `yaml:"total"`
+			VAT           int `yaml:"vat"`
+		} `yaml:"columns"`
+	} `yaml:"lines"`
+	Formats struct {
+		// Month is a Go time layout for the submission month
+		Month string `yaml:"month"`
+		// Amount and Amount3Digit are Excel number formats for amounts with 2 and 3 decimal places
+		Amount       string `yaml:"amount"`
+		Amount3Digit string `yaml:"amount_3_digit"`
+	} `yaml:"formats"`
+	// Labels are text the template must have, to catch a layout used with the wrong template
+	Labels []Label `yaml:"labels"`
+
+	from time.Time
+}
+
+// Cell is a 0-based position on the first sheet
+type Cell struct {
+	Row int `yaml:"row"`
+	Col int `yaml:"col"`
+}
+
+// Label is text that starts the value of a cell in the template
+type Label struct {
+	Row  int    `yaml:"row"`
+	Col  int    `yaml:"col"`
+	Text string `yaml:"text"`
+}
+
+// Layouts returns the bundled template versions, oldest first
+func Layouts() ([]Layout, error) {
+	data, err := bundled.Asset(LayoutsAsset)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLayouts(data)
+}
+
+// ParseLayouts reads a list of layouts in YAML, checks that each has what's needed to fill a form and sorts them
+// by start date
+func ParseLayouts(data []byte) ([]Layout, error) {
+	var layouts []Layout
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&layouts); err != nil {
+		return nil, errors.Wrap(err, "failed to read VAT template layouts")
+	}
+	if len(layouts) == 0 {
+		return nil, fmt.Errorf("no VAT template layouts")
+	}
+	for i := range layouts {
+		l := &layouts[i]
+		from, err := time.Parse("2006-01-02", l.From)
+		if err != nil {
+			return nil, errors.Wrapf(err, "VAT template %s has a bad start date", l.Version)
+		}
+		l.from = from
+		switch {
+		case l.Template == "":
+			return nil, fmt.Errorf("VAT template %s has no template file", l.Version)
+		case l.MaxLines <= 0:
+			return nil, fmt.Errorf("VAT template %s has no receipt lines", l.Version)
+		case len(l.Cells.Name) == 0:
+			return nil, fmt.Errorf("VAT template %s has no name cell", l.Version)
+		}
+		if l.Formats.Month == "" {
+			l.Formats.Month = "Jan-06"
+		}
+		if l.Formats.Amount == "" {
+			l.Formats.Amount = "0.00"
+		}
+		if l.Formats.Amount3Digit == "" {
+			l.Formats.Amount3Digit = "0.000"
+		}
+	}
+	sort.SliceStable(layouts, func(i, j int) bool { return layouts[i].from.Before(layouts[j].from) })
+	return layouts, nil
+}
+
+// LayoutFor returns the latest layout that starts on or before the submission date
+func LayoutFor(layouts []Layout, submitted time.Time) (Layout, error) {
+	var out *Layout
+	for i := range layouts {
+		if !layouts[i].from.After(submitted) {
+			out = &layouts[i]
+		}
+	}
+	if out == nil {
+		return Layout{}, fmt.Errorf("no VAT template for submissions on %s", submitted.Format("2006-01-02"))
+	}
+	return *out, nil
+}
+
+// Check returns an error if the template doesn't have the labels of the layout
+func (l Layout) Check(f *xlsx.File) error {
+	if len(f.Sheets) == 0 {
+		return fmt.Errorf("no sheets in file")
+	}
+	sh := f.Sheets[0]
+	for _, label := range l.Labels {
+		c, err := sh.Cell(label.Row, label.Col)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(strings.TrimSpace(c.Value), label.Text) {
+			return fmt.Errorf("VAT template doesn't match layout %s: expected %q at row %d column %d, found %q", l.Version, label.Text, label.Row, label.Col, c.Value)
+		}
+	}
+	return nil
+}
+
+// Blank returns the bundled template file for the layout
+func (l Layout) Blank() ([]byte, error) {
+	return bundled.Asset(l.Template)
+}
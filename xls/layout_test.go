@@ -0,0 +1,132 @@
+package xls
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLayouts = `
+- version: new
+  from: 2022-07-01
+  template: assets/vat-template-2022.xlsx
+  max_lines: 20
+  cells:
+    name: [{row: 9, col: 0}]
+  lines:
+    first_row: 23
+- version: old
+  from: 2020-01-01
+  template: assets/vat-template.xlsx
+  max_lines: 17
+  cells:
+    name: [{row: 8, col: 0}, {row: 10, col: 0}]
+  lines:
+    first_row: 21
+`
+
+func TestLayoutFor(t *testing.T) {
+	layouts, err := ParseLayouts([]byte(testLayouts))
+	require.NoError(t, err)
+	require.Len(t, layouts, 2)
+	assert.Equal(t, "old", layouts[0].Version)
+	assert.Equal(t, "Jan-06", layouts[0].Formats.Month)
+
+	tt := []struct {
+		name      string
+		submitted time.Time
+		version   string
+		err       bool
+	}{
+		{"before any", time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC), "", true},
+		{"first day", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), "old", false},
+		{"day before new", time.Date(2022, 6, 30, 23, 0, 0, 0, time.UTC), "old", false},
+		{"new", time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC), "new", false},
+		{"later", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), "new", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := LayoutFor(layouts, tc.submitted)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.version, l.Version)
+		})
+	}
+}
+
+func TestParseLayoutsInvalid(t *testing.T) {
+	tt := []struct {
+		name string
+		yaml string
+	}{
+		{"empty", ""},
+		{"bad date", "- {version: x, from: July, template: t.xlsx, max_lines: 1, cells: {name: [{row: 1, col: 1}]}}"},
+		{"no lines", "- {version: x, from: 2020-01-01, template: t.xlsx, cells: {name: [{row: 1, col: 1}]}}"},
+		{"no template", "- {version: x, from: 2020-01-01, max_lines: 1, cells: {name: [{row: 1, col: 1}]}}"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseLayouts([]byte(tc.yaml))
+			assert.Error(t, err)
+		})
+	}
+}
+
+type testLine struct{}
+
+func (testLine) GetVendor() string        { return "Rimi" }
+func (testLine) GetReceiptNumber() string { return "A-1" }
+func (testLine) GetDate() string          { return "02/01/2021" }
+func (testLine) GetTotal() string         { return "10.555" }
+func (testLine) GetVAT() string           { return "1.76" }
+
+// every bundled layout has to match its template and fill the cells it describes
+func TestBundledLayouts(t *testing.T) {
+	layouts, err := Layouts()
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "layouts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for _, l := range layouts {
+		t.Run(l.Version, func(t *testing.T) {
+			blank, err := l.Blank()
+			require.NoError(t, err)
+			f, err := NewFromTemplate(filepath.Join(dir, l.Version+".xlsx"), blank)
+			require.NoError(t, err)
+			require.NoError(t, l.Check(f))
+
+			require.NoError(t, l.WriteName("Mari Maasikas", f))
+			require.NoError(t, l.WriteSubmissionMonth(1, 2021, f))
+			require.NoError(t, l.WriteVATLine(f, testLine{}, l.MaxLines-1))
+			assert.Error(t, l.WriteVATLine(f, testLine{}, l.MaxLines))
+
+			sh := f.Sheets[0]
+			for _, c := range l.Cells.Name {
+				cell, err := sh.Cell(c.Row, c.Col)
+				require.NoError(t, err)
+				assert.Equal(t, "Mari Maasikas", cell.Value)
+			}
+			month, err := sh.Cell(l.Cells.Month[0].Row, l.Cells.Month[0].Col)
+			require.NoError(t, err)
+			assert.Equal(t, "Jan-21", month.Value)
+
+			row := l.Lines.FirstRow + l.MaxLines - 1
+			vendor, err := sh.Cell(row, l.Lines.Columns.Vendor)
+			require.NoError(t, err)
+			assert.Equal(t, "Rimi", vendor.Value)
+			total, err := sh.Cell(row, l.Lines.Columns.Total)
+			require.NoError(t, err)
+			assert.Equal(t, l.Formats.Amount3Digit, total.NumFmt)
+		})
+	}
+}
@@ -10,54 +10,62 @@ import (
 	"github.com/tealeg/xlsx/v3"
 )
 
-// number of rows to the first receipt row (0-based)
-const rowOffset int = 21
-
 type cellOp func() error
 
 // WriteName writes the applicant's name to both the applicant name field and receiver of refund
-func WriteName(name string, f *xlsx.File) error {
-	sh := f.Sheets[0]
-	if err := setString(8, 0, name, sh); err != nil {
-		return fmt.Errorf("error writing name to template: %s", err)
-	}
-	if err := setString(10, 0, name, sh); err != nil {
+func (l Layout) WriteName(name string, f *xlsx.File) error {
+	if err := l.setStrings(l.Cells.Name, name, f); err != nil {
 		return fmt.Errorf("error writing name to template: %s", err)
 	}
 	return nil
 }
 
 // WriteDipNumber
-func WriteDipNumber(num string, f *xlsx.File) error {
-	sh := f.Sheets[0]
-	if err := setString(8, 3, num, sh); err != nil {
+func (l Layout) WriteDipNumber(num string, f *xlsx.File) error {
+	if err := l.setStrings(l.Cells.DiplomaticID, num, f); err != nil {
 		return fmt.Errorf("error writing dip number to template: %s", err)
 	}
 	return nil
 }
 
 // WriteBankInfo
-func WriteBankInfo(info string, f *xlsx.File) error {
-	sh := f.Sheets[0]
-	if err := setString(12, 0, info, sh); err != nil {
+func (l Layout) WriteBankInfo(info string, f *xlsx.File) error {
+	if err := l.setStrings(l.Cells.Bank, info, f); err != nil {
 		return fmt.Errorf("error writing bank info to template: %s", err)
 	}
 	return nil
 }
 
 // WriteSubmissionMonth
-func WriteSubmissionMonth(month int, year int, f *xlsx.File) error {
+func (l Layout) WriteSubmissionMonth(month int, year int, f *xlsx.File) error {
+	if len(f.Sheets) == 0 {
+		return fmt.Errorf("no sheets in file")
+	}
 	sh := f.Sheets[0]
-	if err := setDate(16, 0, month, year, sh); err != nil {
-		return fmt.Errorf("error writing submission month to spreadsheet: %s", err)
+	for _, cell := range l.Cells.Month {
+		if err := setDate(cell.Row, cell.Col, month, year, l.Formats.Month, sh); err != nil {
+			return fmt.Errorf("error writing submission month to spreadsheet: %s", err)
+		}
+		c, err := sh.Cell(cell.Row, cell.Col)
+		if err != nil {
+			return err
+		}
+		c.SetStyle(&xlsx.Style{
+			Alignment: xlsx.Alignment{Horizontal: "right"},
+		})
 	}
-	c, err := sh.Cell(16, 0)
-	if err != nil {
-		return err
+	return nil
+}
+
+func (l Layout) setStrings(cells []Cell, s string, f *xlsx.File) error {
+	if len(f.Sheets) == 0 {
+		return fmt.Errorf("no sheets in file")
+	}
+	for _, c := range cells {
+		if err := setString(c.Row, c.Col, s, f.Sheets[0]); err != nil {
+			return err
+		}
 	}
-	c.SetStyle(&xlsx.Style{
-		Alignment: xlsx.Alignment{Horizontal: "right"},
-	})
 	return nil
 }
 
@@ -71,25 +79,27 @@ type VATLine interface {
 	GetVAT() string
 }
 
-// WriteVATLine writes a VAT line to the Excel spreadsheet
-func WriteVATLine(f *xlsx.File, r VATLine, num int) error {
-	if num > 17 {
-		return fmt.Errorf("unallowed row %d: greater than 17", num)
+// WriteVATLine writes a VAT line to the Excel spreadsheet.  num is 0-based.  Amounts keep the precision they are
+// formatted with, so 3-digit currencies are written with 3 decimal places.
+func (l Layout) WriteVATLine(f *xlsx.File, r VATLine, num int) error {
+	if num < 0 || num >= l.MaxLines {
+		return fmt.Errorf("unallowed row %d: the form has %d lines", num+1, l.MaxLines)
 	}
 
-	row := num + rowOffset
+	row := num + l.Lines.FirstRow
+	col := l.Lines.Columns
 
 	if len(f.Sheets) == 0 {
 		return fmt.Errorf("no sheets in file")
 	}
 	sh := f.Sheets[0]
 	ops := []cellOp{
-		setNumF(row, 0, num+1, sh),
-		setStringF(row, 1, r.GetVendor(), sh),
-		setStringF(row, 2, r.GetReceiptNumber(), sh),
-		setStringF(row, 3, r.GetDate(), sh),
-		setFloatF(row, 4, r.GetTotal(), sh),
-		setFloatF(row, 5, r.GetVAT(), sh),
+		setNumF(row, col.Number, num+1, sh),
+		setStringF(row, col.Vendor, r.GetVendor(), sh),
+		setStringF(row, col.ReceiptNumber, r.GetReceiptNumber(), sh),
+		setStringF(row, col.Date, r.GetDate(), sh),
+		setFloatF(row, col.Total, r.GetTotal(), l.numberFormat(r.GetTotal()), sh),
+		setFloatF(row, col.VAT, r.GetVAT(), l.numberFormat(r.GetVAT()), sh),
 	}
 
 	var errs []string
@@ -136,7 +146,7 @@ func setNum(row, col int, d int, sh *xlsx.Sheet) error {
 	return nil
 }
 
-func setFloat(row, col int, d string, sh *xlsx.Sheet) error {
+func setFloat(row, col int, d string, format string, sh *xlsx.Sheet) error {
 	c, err := sh.Cell(row, col)
 	if err != nil {
 		return err
@@ -145,17 +155,26 @@ func setFloat(row, col int, d string, sh *xlsx.Sheet) error {
 	if err != nil {
 		return err
 	}
-	c.SetFloatWithFormat(f, "0.00")
+	c.SetFloatWithFormat(f, format)
 	return nil
 }
 
-func setDate(row, col int, month int, year int, sh *xlsx.Sheet) error {
+// numberFormat keeps the number of decimal places in d so that 3-digit currencies are not displayed
+// rounded to cents
+func (l Layout) numberFormat(d string) string {
+	if i := strings.LastIndex(d, "."); i >= 0 && len(d)-i-1 == 3 {
+		return l.Formats.Amount3Digit
+	}
+	return l.Formats.Amount
+}
+
+func setDate(row, col int, month int, year int, format string, sh *xlsx.Sheet) error {
 	c, err := sh.Cell(row, col)
 	if err != nil {
 		return err
 	}
 	d := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
-	c.SetString(d.Format("Jan-06"))
+	c.SetString(d.Format(format))
 	return nil
 }
 
@@ -180,8 +199,8 @@ func setNumericF(row, col int, d string, sh *xlsx.Sheet) func() error {
 	}
 }
 
-func setFloatF(row, col int, d string, sh *xlsx.Sheet) func() error {
+func setFloatF(row, col int, d string, format string, sh *xlsx.Sheet) func() error {
 	return func() error {
-		return setFloat(row, col, d, sh)
+		return setFloat(row, col, d, format, sh)
 	}
 }